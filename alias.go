@@ -0,0 +1,132 @@
+package errcode
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Deprecation describe a deprecated code.
+type Deprecation struct {
+	Replacement Code   `json:"replacement,omitempty" yaml:"replacement,omitempty"`
+	Note        string `json:"note,omitempty" yaml:"note,omitempty"`
+}
+
+type deprecation struct {
+	Deprecation
+	used int32 // reported on first use, a hook using the code again doesn't report it twice.
+}
+
+// DeprecationHook is called on the first use of a deprecated code.
+type DeprecationHook func(c Code, d Deprecation)
+
+func logDeprecation(c Code, d Deprecation) {
+	log.Printf("ecode: %d is deprecated, use %d instead: %s", c, d.Replacement, d.Note)
+}
+
+// Alias make old resolve to new in the default registry.
+func Alias(old, new Code) {
+	_defaultRegistry.Alias(old, new)
+}
+
+// Deprecate mark code deprecated in the default registry.
+func Deprecate(code, replacement Code, note string) {
+	_defaultRegistry.Deprecate(code, replacement, note)
+}
+
+// SetDeprecationHook set the hook of the default registry.
+func SetDeprecationHook(hook DeprecationHook) {
+	_defaultRegistry.SetDeprecationHook(hook)
+}
+
+// Alias make old resolve to new, it is used when codes are renumbered and old clients still send the old code.
+// String, Cause, FromProto and Equal resolve aliases.
+// NOTE: it panics if old is already an alias or the alias makes a cycle.
+func (r *Registry) Alias(old, new Code) {
+	must(r.alias(old, new))
+}
+
+func (r *Registry) alias(old, new Code) error {
+	r.mxInfo.Lock()
+	defer r.mxInfo.Unlock()
+	if err := r.checkFrozen(); err != nil {
+		return err
+	}
+	if to, ok := r.aliases[old.Code()]; ok {
+		return fmt.Errorf("ecode: %d already alias of %d", old, to)
+	}
+	for c, ok := new.Code(), true; ok; c, ok = r.aliases[c] {
+		if c == old.Code() {
+			return fmt.Errorf("ecode: alias %d to %d makes a cycle", old, new)
+		}
+	}
+	r.aliases[old.Code()] = new.Code()
+	return nil
+}
+
+// Deprecate mark code deprecated, the deprecation hook is called on its first use,
+// i.e. a Status is created by it.
+func (r *Registry) Deprecate(code, replacement Code, note string) {
+	r.mxInfo.Lock()
+	defer r.mxInfo.Unlock()
+	must(r.checkFrozen())
+	r.deprecations[code.Code()] = &deprecation{Deprecation: Deprecation{Replacement: replacement, Note: note}}
+}
+
+// SetDeprecationHook set the hook called on the first use of a deprecated code, default is logging.
+func (r *Registry) SetDeprecationHook(hook DeprecationHook) {
+	r.deprecationHook.Store(hook)
+}
+
+// Resolve follow the aliases of c, it return c if c is not an alias.
+func (r *Registry) Resolve(c Code) Code {
+	if snap := r.snapshot(); snap != nil {
+		return resolveAlias(snap.aliases, c)
+	}
+	r.mxInfo.RLock()
+	defer r.mxInfo.RUnlock()
+	return resolveAlias(r.aliases, c)
+}
+
+func resolveAlias(aliases map[int]int, c Code) Code {
+	for {
+		to, ok := aliases[c.Code()]
+		if !ok {
+			return c
+		}
+		c = Code(to)
+	}
+}
+
+// Deprecation return the deprecation of c.
+func (r *Registry) Deprecation(c Code) (Deprecation, bool) {
+	if d := r.deprecation(c.Code()); d != nil {
+		return d.Deprecation, true
+	}
+	return Deprecation{}, false
+}
+
+func (r *Registry) deprecation(c int) *deprecation {
+	if snap := r.snapshot(); snap != nil {
+		return snap.deprecations[c]
+	}
+	r.mxInfo.RLock()
+	defer r.mxInfo.RUnlock()
+	return r.deprecations[c]
+}
+
+// use report the first use of a deprecated code.
+func (r *Registry) use(c Code) {
+	d := r.deprecation(c.Code())
+	if d == nil {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&d.used, 0, 1) {
+		return
+	}
+	hook, _ := r.deprecationHook.Load().(DeprecationHook)
+	if hook == nil {
+		hook = logDeprecation
+	}
+	hook(c, d.Deprecation)
+}
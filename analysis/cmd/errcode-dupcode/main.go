@@ -0,0 +1,40 @@
+// Command errcode-dupcode report the codes defined more than once in a module,
+// including the ones in packages which are never linked into the same binary:
+//
+//	go run github.com/SeaseeYoul/errcode/analysis/cmd/errcode-dupcode ./...
+//
+// Every collision is reported at each of its call sites, it exits with 1 if there is any.
+package main
+
+import (
+	"fmt"
+	"github.com/SeaseeYoul/errcode/analysis/dupcode"
+	"os"
+	"strings"
+)
+
+func main() {
+	patterns := os.Args[1:]
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	collisions, err := dupcode.CheckModule("", patterns...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "errcode-dupcode: %v\n", err)
+		os.Exit(2)
+	}
+	for _, c := range collisions {
+		for i, d := range c.Defs {
+			var others []string
+			for j, o := range c.Defs {
+				if j != i {
+					others = append(others, fmt.Sprintf("%s (%s)", o.Pkg, o.Pos))
+				}
+			}
+			fmt.Printf("%s: ecode: %d also defined in %s\n", d.Pos, c.Code, strings.Join(others, ", "))
+		}
+	}
+	if len(collisions) > 0 {
+		os.Exit(1)
+	}
+}
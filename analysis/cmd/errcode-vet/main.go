@@ -0,0 +1,14 @@
+// Command errcode-vet run the dupcode analyzer with go vet:
+//
+//	go build -o errcode-vet github.com/SeaseeYoul/errcode/analysis/cmd/errcode-vet
+//	go vet -vettool=$(pwd)/errcode-vet ./...
+package main
+
+import (
+	"github.com/SeaseeYoul/errcode/analysis/dupcode"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(dupcode.Analyzer)
+}
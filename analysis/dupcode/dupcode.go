@@ -0,0 +1,137 @@
+// Package dupcode define an analyzer which reports codes defined more than once,
+// by the constant argument of errcode.New, errcode.RegisterCode and the addInt-style functions.
+//
+// Codes of the dependencies are carried by facts, so a collision is reported in the package
+// which defines the later code. The analyzer only sees the import closure of a package,
+// CheckModule finds the collisions between packages which don't import each other, e.g. siblings of a library.
+// Codes created by the Registry methods are per registry and not checked.
+package dupcode
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"sort"
+)
+
+const errcodePath = "github.com/SeaseeYoul/errcode"
+
+// funcs define codes by their first argument.
+var funcs = map[string]bool{
+	"New":             true,
+	"RegisterCode":    true,
+	"TryNew":          true,
+	"TryRegisterCode": true,
+	"addCode":         true,
+	"addInt":          true,
+}
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "dupcode",
+	Doc:       "report errcode codes defined more than once across packages",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(Codes)},
+}
+
+// Def is a definition of code.
+type Def struct {
+	Code int64
+	Pos  string // file:line:column
+	Pkg  string
+}
+
+// Codes is the fact of the codes defined by a package.
+type Codes struct {
+	Defs []Def
+}
+
+func (*Codes) AFact() {}
+
+func (c *Codes) String() string { return fmt.Sprintf("codes(%d)", len(c.Defs)) }
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	var defs []Def
+	var poses []ast.Node
+	ins.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		c, ok := codeOf(pass.TypesInfo, call)
+		if !ok {
+			return
+		}
+		defs = append(defs, Def{Code: c, Pos: pass.Fset.Position(call.Pos()).String(), Pkg: pass.Pkg.Path()})
+		poses = append(poses, call)
+	})
+
+	// codes of the dependencies, sorted for a stable report.
+	var deps []Def
+	for _, f := range pass.AllPackageFacts() {
+		if codes, ok := f.Fact.(*Codes); ok {
+			deps = append(deps, codes.Defs...)
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Pkg != deps[j].Pkg {
+			return deps[i].Pkg < deps[j].Pkg
+		}
+		return deps[i].Pos < deps[j].Pos
+	})
+	// collisions between the dependencies are reported at their call sites by CheckModule.
+	seen := map[int64]Def{}
+	for _, d := range deps {
+		if _, ok := seen[d.Code]; !ok {
+			seen[d.Code] = d
+		}
+	}
+	for i, d := range defs {
+		if first, ok := seen[d.Code]; ok {
+			pass.Reportf(poses[i].Pos(), "ecode: %d already exist in %s (%s)", d.Code, first.Pkg, first.Pos)
+			continue
+		}
+		seen[d.Code] = d
+	}
+
+	if len(defs) > 0 {
+		pass.ExportPackageFact(&Codes{Defs: defs})
+	}
+	return nil, nil
+}
+
+// codeOf return the code defined by call, the constant first argument of a function in funcs.
+func codeOf(info *types.Info, call *ast.CallExpr) (int64, bool) {
+	if len(call.Args) == 0 || !isDefineFunc(info, call) {
+		return 0, false
+	}
+	tv, ok := info.Types[call.Args[0]]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(tv.Value)
+}
+
+// isDefineFunc report whether call is a package level function of errcode which defines a code.
+func isDefineFunc(info *types.Info, call *ast.CallExpr) bool {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return false
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != errcodePath {
+		return false
+	}
+	if sig, ok := fn.Type().(*types.Signature); !ok || sig.Recv() != nil {
+		return false
+	}
+	return funcs[fn.Name()]
+}
@@ -0,0 +1,37 @@
+package dupcode_test
+
+import (
+	"fmt"
+	"github.com/SeaseeYoul/errcode/analysis/dupcode"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), dupcode.Analyzer, "a", "b", "c")
+}
+
+func TestCheckModule(t *testing.T) {
+	// x and y are siblings, no package imports both of them.
+	collisions, err := dupcode.CheckModule(filepath.Join("testdata", "module"), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range collisions {
+		for _, d := range c.Defs {
+			got = append(got, fmt.Sprintf("%d %s %s", c.Code, d.Pkg, filepath.Base(d.Pos)))
+		}
+	}
+	want := []string{
+		"-201 example.com/lib/x x.go:6:13",
+		"-201 example.com/lib/y y.go:5:15",
+		"2 example.com/lib/x x.go:7:13",
+		"2 github.com/SeaseeYoul/errcode errcode.go:9:15",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("collisions = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package dupcode
+
+import (
+	"fmt"
+	"go/ast"
+	"golang.org/x/tools/go/packages"
+	"sort"
+	"strings"
+)
+
+// Collision is a code defined more than once, Defs are sorted by package then position.
+type Collision struct {
+	Code int64
+	Defs []Def
+}
+
+// CheckModule load the packages matched by patterns in dir, e.g. "./...", and return the codes defined
+// more than once by them and their dependencies, whether or not the packages are linked into the same binary.
+func CheckModule(dir string, patterns ...string) ([]Collision, error) {
+	// only the packages which import errcode can define a code, the others are not type checked.
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedImports | packages.NeedDeps, Dir: dir}
+	roots, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		if _, ok := pkg.Imports[errcodePath]; ok || pkg.PkgPath == errcodePath {
+			paths = append(paths, pkg.PkgPath)
+		}
+	})
+	if err := loadError(roots); err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	cfg.Mode = packages.NeedName | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
+	pkgs, err := packages.Load(cfg, paths...)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadError(pkgs); err != nil {
+		return nil, err
+	}
+	defs := map[int64][]Def{}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if c, ok := codeOf(pkg.TypesInfo, call); ok {
+					defs[c] = append(defs[c], Def{Code: c, Pos: pkg.Fset.Position(call.Pos()).String(), Pkg: pkg.PkgPath})
+				}
+				return true
+			})
+		}
+	}
+
+	var collisions []Collision
+	for c, ds := range defs {
+		if len(ds) < 2 {
+			continue
+		}
+		sort.Slice(ds, func(i, j int) bool {
+			if ds[i].Pkg != ds[j].Pkg {
+				return ds[i].Pkg < ds[j].Pkg
+			}
+			return ds[i].Pos < ds[j].Pos
+		})
+		collisions = append(collisions, Collision{Code: c, Defs: ds})
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Code < collisions[j].Code })
+	return collisions, nil
+}
+
+// loadError report the errors of pkgs and their dependencies at once.
+func loadError(pkgs []*packages.Package) error {
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			errs = append(errs, err.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("dupcode: %d error(s) in loading packages:\n\t%s", len(errs), strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
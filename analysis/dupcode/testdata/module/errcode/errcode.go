@@ -0,0 +1,9 @@
+package errcode
+
+type Code int
+
+func New(e int) Code { return Code(e) }
+
+func addInt(c int, httpCode int, message string) Code { return Code(c) }
+
+var Unknown = addInt(2, 500, "unknown")
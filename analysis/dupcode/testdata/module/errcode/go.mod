@@ -0,0 +1,3 @@
+module github.com/SeaseeYoul/errcode
+
+go 1.15
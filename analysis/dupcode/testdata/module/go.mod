@@ -0,0 +1,7 @@
+module example.com/lib
+
+go 1.15
+
+require github.com/SeaseeYoul/errcode v0.0.0
+
+replace github.com/SeaseeYoul/errcode => ./errcode
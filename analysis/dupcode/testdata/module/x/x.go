@@ -0,0 +1,8 @@
+package x
+
+import "github.com/SeaseeYoul/errcode"
+
+var (
+	NotFound = errcode.New(-201)
+	Internal = errcode.New(2)
+)
@@ -0,0 +1,5 @@
+package y
+
+import "github.com/SeaseeYoul/errcode"
+
+var Missing = errcode.New(-201)
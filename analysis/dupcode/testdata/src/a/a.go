@@ -0,0 +1,13 @@
+package a // want package:"codes\\(3\\)"
+
+import "github.com/SeaseeYoul/errcode"
+
+const base = -100
+
+var (
+	X = errcode.New(base - 1)
+	Y = errcode.New(-102)
+	Z = errcode.New(-102) // want `ecode: -102 already exist in a \(.*a.go:9:6\)`
+
+	R = (&errcode.Registry{}).New(-102)
+)
@@ -0,0 +1,5 @@
+package b // want package:"codes\\(1\\)"
+
+import "github.com/SeaseeYoul/errcode"
+
+var Z = errcode.New(-101)
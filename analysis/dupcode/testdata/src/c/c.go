@@ -0,0 +1,12 @@
+package main // want package:"codes\\(1\\)"
+
+import (
+	_ "a"
+	_ "b"
+
+	"github.com/SeaseeYoul/errcode"
+)
+
+var U = errcode.New(2) // want `ecode: 2 already exist in github.com/SeaseeYoul/errcode`
+
+func main() {}
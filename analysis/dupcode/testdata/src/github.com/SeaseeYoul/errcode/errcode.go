@@ -0,0 +1,16 @@
+package errcode
+
+type Code int
+
+type Registry struct{}
+
+func (r *Registry) New(e int) Code { return Code(e) }
+
+func New(e int) Code { return Code(e) }
+
+func addInt(c int, httpCode int, message string) Code { return Code(c) }
+
+var (
+	OK      = addInt(0, 200, "")
+	Unknown = addInt(2, 500, "unknown")
+)
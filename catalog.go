@@ -0,0 +1,382 @@
+package errcode
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CatalogFormat is the encoding of a catalog file.
+type CatalogFormat string
+
+const (
+	FormatJSON     CatalogFormat = "json"
+	FormatYAML     CatalogFormat = "yaml"
+	FormatCSV      CatalogFormat = "csv"      // export only
+	FormatMarkdown CatalogFormat = "markdown" // export only
+)
+
+// CatalogEntry describe a code in catalog.
+type CatalogEntry struct {
+	Code     int               `json:"code" yaml:"code"`
+	Name     string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Message  string            `json:"message" yaml:"message"`
+	HttpCode int               `json:"http_code,omitempty" yaml:"http_code,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// Aliases are the old codes resolved to this code.
+	Aliases    []int        `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Deprecated *Deprecation `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	// Source is where the code is registered, it is ignored when loaded.
+	Source *Source `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// CatalogError collect all the problems found in a catalog.
+type CatalogError struct {
+	Errors []error
+}
+
+func (e *CatalogError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("ecode: %d error(s) in catalog:\n\t%s", len(e.Errors), strings.Join(msgs, "\n\t"))
+}
+
+// LoadCatalog read entries from rd and register them in the default registry.
+func LoadCatalog(rd io.Reader, format CatalogFormat) error {
+	return _defaultRegistry.LoadCatalog(rd, format)
+}
+
+// LoadCatalogFile read entries from file and register them in the default registry.
+func LoadCatalogFile(path string) error {
+	return _defaultRegistry.LoadCatalogFile(path)
+}
+
+// LoadCatalogFile read a catalog file, the format is decided by the extension (.json, .yaml or .yml).
+func (r *Registry) LoadCatalogFile(path string) error {
+	format, err := catalogFormatOf(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.LoadCatalog(bytes.NewReader(data), format)
+}
+
+// LoadCatalog read entries from rd and register them.
+// Nothing is registered if any entry is duplicate or malformed, all problems are reported in a *CatalogError.
+// The entries are checked before registered, a conflict found while registering skip only the entry.
+func (r *Registry) LoadCatalog(rd io.Reader, format CatalogFormat) error {
+	if err := r.checkFrozen(); err != nil {
+		return err
+	}
+	entries, err := decodeCatalog(rd, format)
+	if err != nil {
+		return err
+	}
+	if err := r.checkCatalog(entries); err != nil {
+		return err
+	}
+	var errs []error
+	for i, e := range entries {
+		if _, err := r.addInt(e.Code, e.HttpCode, e.Message); err != nil {
+			// the code is taken or rejected by the conflict policy after checked.
+			errs = append(errs, fmt.Errorf("entry #%d (code %d): %v", i, e.Code, err))
+			continue
+		}
+		r.registerEntryInfo(e.Code, e.Name, e.Metadata)
+		if meta, _ := parseMeta(e.Metadata); meta != (Meta{}) {
+			r.RegisterMeta(e.Code, meta)
+		}
+		for _, old := range e.Aliases {
+			if err := r.alias(Code(old), Code(e.Code)); err != nil {
+				errs = append(errs, fmt.Errorf("entry #%d (code %d): %v", i, e.Code, err))
+			}
+		}
+		if e.Deprecated != nil {
+			r.Deprecate(Code(e.Code), e.Deprecated.Replacement, e.Deprecated.Note)
+		}
+	}
+	if len(errs) > 0 {
+		return &CatalogError{Errors: errs}
+	}
+	return nil
+}
+
+func catalogFormatOf(path string) (CatalogFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("ecode: unknown catalog format of %s", path)
+}
+
+func decodeCatalog(rd io.Reader, format CatalogFormat) (entries []CatalogEntry, err error) {
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(rd)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&entries)
+	case FormatYAML:
+		var data []byte
+		if data, err = ioutil.ReadAll(rd); err == nil {
+			err = yaml.UnmarshalStrict(data, &entries)
+		}
+	default:
+		return nil, fmt.Errorf("ecode: unknown catalog format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ecode: decode %s catalog: %v", format, err)
+	}
+	return entries, nil
+}
+
+func (r *Registry) checkCatalog(entries []CatalogEntry) error {
+	var errs []error
+	codes := map[int]int{}
+	names := map[string]int{}
+	aliases := map[int]int{}
+	policy := r.CodePolicy()
+	for i, e := range entries {
+		report := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("entry #%d (code %d): %s", i, e.Code, fmt.Sprintf(format, args...)))
+		}
+		if err := policy.check(e.Code); err != nil {
+			report("%v", err)
+		}
+		if m := r.module(e.Code); m != nil {
+			report("reserved by module %s", m.name)
+		}
+		if e.Message == "" {
+			report("message is empty")
+		}
+		if e.HttpCode != 0 && (e.HttpCode < 100 || e.HttpCode > 599) {
+			report("invalid http code %d", e.HttpCode)
+		}
+		if _, err := parseMeta(e.Metadata); err != nil {
+			report("%v", err)
+		}
+		if j, ok := codes[e.Code]; ok {
+			report("duplicate code of entry #%d", j)
+		} else if r.exist(e.Code) {
+			report("already exist")
+		}
+		if e.Name != "" {
+			if j, ok := names[e.Name]; ok {
+				report("duplicate name %s of entry #%d", e.Name, j)
+			}
+			names[e.Name] = i
+		}
+		codes[e.Code] = i
+		for _, old := range e.Aliases {
+			if old == e.Code {
+				report("alias to itself")
+			} else if j, ok := aliases[old]; ok {
+				report("alias %d already in entry #%d", old, j)
+			} else if to := r.Resolve(Code(old)); to != Code(old) {
+				report("ecode: %d already alias of %d", old, to)
+			}
+			aliases[old] = i
+		}
+	}
+	if len(errs) > 0 {
+		return &CatalogError{Errors: errs}
+	}
+	return nil
+}
+
+// Catalog return a snapshot of the default registry.
+func Catalog() []CatalogEntry {
+	return _defaultRegistry.Catalog()
+}
+
+// Catalog return a snapshot of every registered code, sorted by code.
+func (r *Registry) Catalog() []CatalogEntry {
+	r.mxCodes.RLock()
+	entries := make([]CatalogEntry, 0, len(r.codes))
+	for c := range r.codes {
+		entries = append(entries, CatalogEntry{Code: c})
+	}
+	r.mxCodes.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Code < entries[j].Code
+	})
+	r.fillEntries(entries)
+	return entries
+}
+
+// fillEntries fill the message, http code, name and metadata of entries by their codes.
+func (r *Registry) fillEntries(entries []CatalogEntry) {
+	r.mxCodes.RLock()
+	for i := range entries {
+		if src, ok := r.sources[entries[i].Code]; ok {
+			entries[i].Source = &src
+		}
+	}
+	r.mxCodes.RUnlock()
+
+	r.mxMessages.RLock()
+	for i := range entries {
+		entries[i].Message = r.messages[entries[i].Code]
+	}
+	r.mxMessages.RUnlock()
+
+	r.mxHttpCodes.RLock()
+	for i := range entries {
+		entries[i].HttpCode = r.httpCodes[entries[i].Code]
+	}
+	r.mxHttpCodes.RUnlock()
+
+	r.mxInfo.RLock()
+	for i := range entries {
+		entries[i].Name = r.names[entries[i].Code]
+		md := map[string]string{}
+		for k, v := range r.metadata[entries[i].Code] {
+			md[k] = v
+		}
+		r.metas[entries[i].Code].metadata(md)
+		if len(md) > 0 {
+			entries[i].Metadata = md
+		}
+		if d := r.deprecations[entries[i].Code]; d != nil {
+			deprecated := d.Deprecation
+			entries[i].Deprecated = &deprecated
+		}
+	}
+	if len(r.aliases) > 0 {
+		index := make(map[int]int, len(entries))
+		for i := range entries {
+			index[entries[i].Code] = i
+		}
+		for old := range r.aliases {
+			if i, ok := index[resolveAlias(r.aliases, Code(old)).Code()]; ok {
+				entries[i].Aliases = append(entries[i].Aliases, old)
+			}
+		}
+		for i := range entries {
+			sort.Sort(sort.Reverse(sort.IntSlice(entries[i].Aliases)))
+		}
+	}
+	r.mxInfo.RUnlock()
+}
+
+// EncodeCatalog write entries to w in format.
+func EncodeCatalog(w io.Writer, entries []CatalogEntry, format CatalogFormat) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case FormatYAML:
+		data, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case FormatCSV:
+		return encodeCatalogCSV(w, entries)
+	case FormatMarkdown:
+		return encodeCatalogMarkdown(w, entries)
+	}
+	return fmt.Errorf("ecode: unknown catalog format %q", format)
+}
+
+func encodeCatalogCSV(w io.Writer, entries []CatalogEntry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"code", "name", "message", "http_code", "metadata", "aliases", "deprecated", "source"})
+	for _, e := range entries {
+		_ = cw.Write([]string{
+			strconv.Itoa(e.Code),
+			e.Name,
+			e.Message,
+			httpCodeString(e.HttpCode),
+			metadataString(e.Metadata),
+			aliasesString(e.Aliases),
+			deprecatedString(e.Deprecated),
+			sourceString(e.Source),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func encodeCatalogMarkdown(w io.Writer, entries []CatalogEntry) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("| Code | Name | Message | HTTP Code | Metadata | Aliases | Deprecated | Source |\n")
+	buf.WriteString("| ---: | --- | --- | ---: | --- | --- | --- | --- |\n")
+	cell := strings.NewReplacer("|", "\\|", "\n", "<br>")
+	for _, e := range entries {
+		fmt.Fprintf(buf, "| %d | %s | %s | %s | %s | %s | %s | %s |\n",
+			e.Code,
+			cell.Replace(e.Name),
+			cell.Replace(e.Message),
+			httpCodeString(e.HttpCode),
+			cell.Replace(metadataString(e.Metadata)),
+			aliasesString(e.Aliases),
+			cell.Replace(deprecatedString(e.Deprecated)),
+			cell.Replace(sourceString(e.Source)))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func httpCodeString(httpCode int) string {
+	if httpCode == 0 {
+		return ""
+	}
+	return strconv.Itoa(httpCode)
+}
+
+// metadataString format metadata as sorted "k=v" pairs separated by ";".
+func metadataString(md map[string]string) string {
+	pairs := make([]string, 0, len(md))
+	for k, v := range md {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+func aliasesString(aliases []int) string {
+	s := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		s = append(s, strconv.Itoa(a))
+	}
+	return strings.Join(s, ";")
+}
+
+// deprecatedString format d as "replaced by -1: note", a zero replacement means there is none.
+func deprecatedString(d *Deprecation) string {
+	if d == nil {
+		return ""
+	}
+	s := "deprecated"
+	if d.Replacement != 0 {
+		s = fmt.Sprintf("replaced by %d", d.Replacement)
+	}
+	if d.Note != "" {
+		s += ": " + d.Note
+	}
+	return s
+}
+
+func sourceString(s *Source) string {
+	if s == nil {
+		return ""
+	}
+	return s.String()
+}
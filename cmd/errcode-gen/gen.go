@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"gopkg.in/yaml.v2"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Spec is the input of errcode-gen.
+type Spec struct {
+	Package string     `yaml:"package"`
+	Codes   []CodeSpec `yaml:"codes"`
+}
+
+// CodeSpec describe a code and its constructor.
+type CodeSpec struct {
+	Name     string    `yaml:"name"`
+	Code     int       `yaml:"code"`
+	HttpCode int       `yaml:"http_code"`
+	Message  string    `yaml:"message"`
+	Format   string    `yaml:"format"` // format of the constructor, default is message.
+	Args     []ArgSpec `yaml:"args"`
+}
+
+// ArgSpec is an argument of the constructor.
+type ArgSpec struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+func parseSpec(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, err
+	}
+	if err := spec.check(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// check report every problem of the spec at once.
+func (s *Spec) check() error {
+	var errs []string
+	if !token.IsIdentifier(s.Package) {
+		errs = append(errs, fmt.Sprintf("invalid package name %q", s.Package))
+	}
+	codes := map[int]string{}
+	names := map[string]struct{}{}
+	constants := make(map[string]struct{}, len(s.Codes))
+	for _, c := range s.Codes {
+		constants[c.Name] = struct{}{}
+	}
+	for i, c := range s.Codes {
+		report := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Sprintf("codes[%d] %s: %s", i, c.Name, fmt.Sprintf(format, args...)))
+		}
+		if !token.IsExported(c.Name) || !token.IsIdentifier(c.Name) {
+			report("name must be an exported identifier")
+		}
+		if _, ok := names[c.Name]; ok {
+			report("duplicate name")
+		}
+		names[c.Name] = struct{}{}
+		// the constructor of c is declared in the same scope as the constants.
+		if _, ok := constants[constructorName(c.Name)]; ok {
+			report("constructor %s collides with the code of the same name", constructorName(c.Name))
+		}
+		if name, ok := codes[c.Code]; ok {
+			report("duplicate code %d of %s", c.Code, name)
+		} else {
+			codes[c.Code] = c.Name
+		}
+		if c.Message == "" {
+			report("message is empty")
+		}
+		if len(c.Args) > 0 && c.Format == "" {
+			report("format is required by args")
+		}
+		for _, a := range c.Args {
+			if !token.IsIdentifier(a.Name) || a.Type == "" {
+				report("invalid arg %q %q", a.Name, a.Type)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d error(s) in spec:\n\t%s", len(errs), strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+var codeTemplate = template.Must(template.New("errcode").Funcs(template.FuncMap{
+	"quote":       strconv.Quote,
+	"constructor": constructorName,
+	"format":      formatOf,
+	"params":      params,
+	"line":        func(s string) string { return strings.Join(strings.Fields(s), " ") },
+}).Parse(`// Code generated by errcode-gen. DO NOT EDIT.
+// source: {{.Source}}
+
+package {{.Package}}
+
+import "github.com/SeaseeYoul/errcode"
+
+const (
+{{- range .Codes}}
+	// {{.Name}} {{line .Message}}
+	{{.Name}} errcode.Code = {{.Code}}
+{{- end}}
+)
+
+func init() {
+{{- range .Codes}}
+	errcode.New(int({{.Name}}))
+	errcode.RegisterMessage(int({{.Name}}), {{quote .Message}})
+{{- if .HttpCode}}
+	errcode.RegisterHttpCode(int({{.Name}}), {{.HttpCode}})
+{{- end}}
+{{- end}}
+}
+{{range .Codes}}
+// {{constructor .Name}} new a status of {{.Name}}.
+func {{constructor .Name}}({{params .Args}}) *errcode.Status {
+	return errcode.Errorf({{.Name}}, {{quote (format .)}}{{range .Args}}, {{.Name}}{{end}})
+}
+{{end}}`))
+
+// constructorName return the name of the constructor generated for the code name.
+func constructorName(name string) string {
+	return "Err" + name
+}
+
+// formatOf return the format of the constructor, % in message is escaped.
+func formatOf(c CodeSpec) string {
+	if c.Format != "" {
+		return c.Format
+	}
+	return strings.Replace(c.Message, "%", "%%", -1)
+}
+
+func params(args []ArgSpec) string {
+	ps := make([]string, 0, len(args))
+	for _, a := range args {
+		ps = append(ps, a.Name+" "+a.Type)
+	}
+	return strings.Join(ps, ", ")
+}
+
+func generate(spec *Spec, source string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := codeTemplate.Execute(buf, struct {
+		*Spec
+		Source string
+	}{spec, source})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
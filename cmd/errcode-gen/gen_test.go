@@ -0,0 +1,86 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	spec, err := parseSpec([]byte(`
+package: account
+codes:
+  - name: UserNotFound
+    code: -1234
+    http_code: 404
+    message: user not found
+    format: user %s not found
+    args:
+      - name: userID
+        type: string
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(spec, "account.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the generated code must compile against errcode.
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "account_gen.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse generated code: %v\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("account", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("type-check generated code: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"UserNotFound errcode.Code = -1234",
+		"errcode.RegisterHttpCode(int(UserNotFound), 404)",
+		"func ErrUserNotFound(userID string) *errcode.Status {",
+		`return errcode.Errorf(UserNotFound, "user %s not found", userID)`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code miss %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestSpecDuplicate(t *testing.T) {
+	_, err := parseSpec([]byte(`
+package: account
+codes:
+  - {name: A, code: -1, message: a}
+  - {name: A, code: -2, message: b}
+  - {name: B, code: -1, message: c}
+`))
+	if err == nil {
+		t.Fatal("duplicate spec should fail")
+	}
+	for _, want := range []string{"codes[1] A: duplicate name", "codes[2] B: duplicate code -1 of A"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error miss %q: %v", want, err)
+		}
+	}
+}
+
+func TestSpecConstructorCollision(t *testing.T) {
+	_, err := parseSpec([]byte(`
+package: account
+codes:
+  - {name: Foo, code: -1, message: foo}
+  - {name: ErrFoo, code: -2, message: err foo}
+`))
+	if err == nil {
+		t.Fatal("constructor collision should fail")
+	}
+	if want := "codes[0] Foo: constructor ErrFoo collides with the code of the same name"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error miss %q: %v", want, err)
+	}
+}
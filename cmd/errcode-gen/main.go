@@ -0,0 +1,61 @@
+// Command errcode-gen generate Code constants, registration and typed constructors from a YAML spec.
+//
+//	//go:generate go run github.com/SeaseeYoul/errcode/cmd/errcode-gen -spec errors.yaml -out errors_gen.go
+//
+// The spec looks like:
+//
+//	package: account
+//	codes:
+//	  - name: UserNotFound
+//	    code: -1234
+//	    http_code: 404
+//	    message: user not found
+//	    format: user %s not found
+//	    args:
+//	      - name: userID
+//	        type: string
+//
+// which generates the constant UserNotFound and the constructor ErrUserNotFound(userID string) *errcode.Status.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "path of the YAML spec")
+	outPath := flag.String("out", "", "path of the generated go file, default is stdout")
+	flag.Parse()
+	if *specPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*specPath, *outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "errcode-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, outPath string) error {
+	data, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	spec, err := parseSpec(data)
+	if err != nil {
+		return err
+	}
+	src, err := generate(spec, filepath.Base(specPath))
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(outPath, src, 0644)
+}
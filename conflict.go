@@ -0,0 +1,113 @@
+package errcode
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"log"
+	"sync/atomic"
+)
+
+// ConflictKind is what is registered twice.
+type ConflictKind string
+
+const (
+	ConflictCode     ConflictKind = "code"
+	ConflictRange    ConflictKind = "range" // the code is reserved by a module.
+	ConflictMessage  ConflictKind = "message"
+	ConflictHttpCode ConflictKind = "http code"
+)
+
+// ConflictError is returned when a code is registered twice,
+// or a message or http code is registered with a different value under PolicyError.
+type ConflictError struct {
+	Kind     ConflictKind
+	Code     int
+	Existing string // existing message, http code or module.
+	Incoming string
+	// where the code is registered, only for ConflictCode.
+	ExistingSource Source
+	IncomingSource Source
+}
+
+func (e *ConflictError) Error() string {
+	switch e.Kind {
+	case ConflictCode:
+		return fmt.Sprintf("ecode: %d already exist, registered at %s, again at %s", e.Code, e.ExistingSource, e.IncomingSource)
+	case ConflictRange:
+		return fmt.Sprintf("ecode: %d is reserved by module %s", e.Code, e.Existing)
+	}
+	return fmt.Sprintf("ecode: %s of %d already exist: %q, got %q", e.Kind, e.Code, e.Existing, e.Incoming)
+}
+
+// ConflictPolicy decide what to do when a message or http code is registered with a different value.
+// Codes are always unique.
+type ConflictPolicy int32
+
+const (
+	PolicyOverride  ConflictPolicy = iota // the later one wins, it is the default.
+	PolicyKeepFirst                       // the later one is ignored.
+	PolicyWarn                            // log the conflict, the later one wins.
+	PolicyError                           // return a *ConflictError, Register* without error panic.
+)
+
+// SetConflictPolicy set the conflict policy of the default registry.
+func SetConflictPolicy(p ConflictPolicy) {
+	_defaultRegistry.SetConflictPolicy(p)
+}
+
+// SetConflictPolicy set the conflict policy of messages and http codes.
+func (r *Registry) SetConflictPolicy(p ConflictPolicy) {
+	atomic.StoreInt32(&r.policy, int32(p))
+}
+
+// ConflictPolicy return the conflict policy of messages and http codes.
+func (r *Registry) ConflictPolicy() ConflictPolicy {
+	return ConflictPolicy(atomic.LoadInt32(&r.policy))
+}
+
+// resolve apply the policy on conflict, the value should be written if write is true.
+func (r *Registry) resolve(conflict *ConflictError) (write bool, err error) {
+	switch r.ConflictPolicy() {
+	case PolicyKeepFirst:
+		return false, nil
+	case PolicyWarn:
+		log.Printf("%v, override it", conflict)
+		return true, nil
+	case PolicyError:
+		return false, conflict
+	}
+	return true, nil
+}
+
+func mustCode(c Code, err error) Code {
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// TryNew is New which return error instead of panic.
+func TryNew(e int) (Code, error) {
+	return _defaultRegistry.TryNew(e)
+}
+
+// TryRegisterCode is RegisterCode which return error instead of panic.
+func TryRegisterCode(c code.Code, httpCode int, message string) (Code, error) {
+	return _defaultRegistry.TryRegisterCode(c, httpCode, message)
+}
+
+// TryRegisterMessage is RegisterMessage which return error instead of panic.
+func TryRegisterMessage(code int, message string) error {
+	return _defaultRegistry.TryRegisterMessage(code, message)
+}
+
+// TryRegisterHttpCode is RegisterHttpCode which return error instead of panic.
+func TryRegisterHttpCode(code int, httpCode int) error {
+	return _defaultRegistry.TryRegisterHttpCode(code, httpCode)
+}
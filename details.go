@@ -0,0 +1,232 @@
+package errcode
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// DetailResolver resolve the type of details, *protoregistry.Types implement it.
+type DetailResolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+type detailResolver struct {
+	r DetailResolver
+}
+
+// resolver return the resolver, default is protoregistry.GlobalTypes.
+func (dr *detailResolver) resolver() DetailResolver {
+	if dr != nil && dr.r != nil {
+		return dr.r
+	}
+	return protoregistry.GlobalTypes
+}
+
+// SetDetailResolver set the resolver of details of the default registry.
+func SetDetailResolver(r DetailResolver) {
+	_defaultRegistry.SetDetailResolver(r)
+}
+
+// SetDetailResolver set the resolver used to decode details of Status with this registry,
+// nil to use protoregistry.GlobalTypes.
+func (r *Registry) SetDetailResolver(resolver DetailResolver) {
+	r.detailResolver.Store(&detailResolver{r: resolver})
+}
+
+// DetailResolver return the resolver of details, default is protoregistry.GlobalTypes.
+func (r *Registry) DetailResolver() DetailResolver {
+	return r.loadDetailResolver().resolver()
+}
+
+// loadDetailResolver return the resolver set by SetDetailResolver, nil if it is not set.
+// It is a new pointer for every SetDetailResolver, so it can key the details cache whatever the resolver is.
+func (r *Registry) loadDetailResolver() *detailResolver {
+	dr, _ := r.detailResolver.Load().(*detailResolver)
+	return dr
+}
+
+// Details return every detail, decoded by the resolver of the registry carried by the context.
+// A detail which can't be decoded is returned as *anypb.Any.
+// Decoded details are cached by the resolver, they must not be modified.
+func (s *Status) Details() []interface{} {
+	if s == nil || s.s == nil {
+		return nil
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.symbolizeLocked()
+	dr := RegistryFromContext(s.Context()).loadDetailResolver()
+	if dr != s.decoder {
+		// the context or the resolver of its registry is changed.
+		s.details, s.decoder = nil, dr
+	}
+	if len(s.details) < len(s.s.Details) {
+		opts := proto.UnmarshalOptions{Resolver: dr.resolver()}
+		for _, any := range s.s.Details[len(s.details):] {
+			s.details = append(s.details, decodeDetail(any, opts))
+		}
+	}
+	return append([]interface{}(nil), s.details...)
+}
+
+func decodeDetail(any *anypb.Any, opts proto.UnmarshalOptions) interface{} {
+	m, err := anypb.UnmarshalNew(any, opts)
+	if err != nil {
+		return any
+	}
+	return m
+}
+
+// detail decode the first detail of the type of m into m, report whether there is one.
+// The typed getters return nil if there is none.
+func (s *Status) detail(m proto.Message) bool {
+	if s == nil || s.s == nil {
+		return false
+	}
+	s.symbolize()
+	for _, any := range s.s.Details {
+		if !any.MessageIs(m) {
+			continue
+		}
+		if err := any.UnmarshalTo(m); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// withDetail attach m, errdetails types are always marshalable.
+func (s *Status) withDetail(m proto.Message) *Status {
+	st, _ := s.WithDetails(m)
+	return st
+}
+
+// WithBadRequest attach a errdetails.BadRequest.
+func (s *Status) WithBadRequest(d *errdetails.BadRequest) *Status {
+	return s.withDetail(d)
+}
+
+// BadRequest return the first errdetails.BadRequest.
+func (s *Status) BadRequest() (*errdetails.BadRequest, bool) {
+	d := &errdetails.BadRequest{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithRetryInfo attach a errdetails.RetryInfo.
+func (s *Status) WithRetryInfo(d *errdetails.RetryInfo) *Status {
+	return s.withDetail(d)
+}
+
+// RetryInfo return the first errdetails.RetryInfo.
+func (s *Status) RetryInfo() (*errdetails.RetryInfo, bool) {
+	d := &errdetails.RetryInfo{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithQuotaFailure attach a errdetails.QuotaFailure.
+func (s *Status) WithQuotaFailure(d *errdetails.QuotaFailure) *Status {
+	return s.withDetail(d)
+}
+
+// QuotaFailure return the first errdetails.QuotaFailure.
+func (s *Status) QuotaFailure() (*errdetails.QuotaFailure, bool) {
+	d := &errdetails.QuotaFailure{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithErrorInfo attach a errdetails.ErrorInfo.
+func (s *Status) WithErrorInfo(d *errdetails.ErrorInfo) *Status {
+	return s.withDetail(d)
+}
+
+// ErrorInfo return the first errdetails.ErrorInfo.
+func (s *Status) ErrorInfo() (*errdetails.ErrorInfo, bool) {
+	d := &errdetails.ErrorInfo{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithResourceInfo attach a errdetails.ResourceInfo.
+func (s *Status) WithResourceInfo(d *errdetails.ResourceInfo) *Status {
+	return s.withDetail(d)
+}
+
+// ResourceInfo return the first errdetails.ResourceInfo.
+func (s *Status) ResourceInfo() (*errdetails.ResourceInfo, bool) {
+	d := &errdetails.ResourceInfo{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithPreconditionFailure attach a errdetails.PreconditionFailure.
+func (s *Status) WithPreconditionFailure(d *errdetails.PreconditionFailure) *Status {
+	return s.withDetail(d)
+}
+
+// PreconditionFailure return the first errdetails.PreconditionFailure.
+func (s *Status) PreconditionFailure() (*errdetails.PreconditionFailure, bool) {
+	d := &errdetails.PreconditionFailure{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithHelp attach a errdetails.Help.
+func (s *Status) WithHelp(d *errdetails.Help) *Status {
+	return s.withDetail(d)
+}
+
+// Help return the first errdetails.Help.
+func (s *Status) Help() (*errdetails.Help, bool) {
+	d := &errdetails.Help{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithRequestInfo attach a errdetails.RequestInfo.
+func (s *Status) WithRequestInfo(d *errdetails.RequestInfo) *Status {
+	return s.withDetail(d)
+}
+
+// RequestInfo return the first errdetails.RequestInfo.
+func (s *Status) RequestInfo() (*errdetails.RequestInfo, bool) {
+	d := &errdetails.RequestInfo{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// WithLocalizedMessageDetail attach a errdetails.LocalizedMessage,
+// use WithLocalizedMessage to attach the message in the locale carried by the context.
+func (s *Status) WithLocalizedMessageDetail(d *errdetails.LocalizedMessage) *Status {
+	return s.withDetail(d)
+}
+
+// LocalizedMessage return the first errdetails.LocalizedMessage.
+func (s *Status) LocalizedMessage() (*errdetails.LocalizedMessage, bool) {
+	d := &errdetails.LocalizedMessage{}
+	if !s.detail(d) {
+		return nil, false
+	}
+	return d, true
+}
@@ -1,13 +1,38 @@
 package errcode
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	pkgerrors "github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
 	defer func() {
-		errStr := recover()
-		ExpectEQ(t, "ecode: -1 already exist", errStr, "New duplicate ecode should cause panic")
+		errStr, _ := recover().(string)
+		ExpectTrue(t, strings.HasPrefix(errStr, "ecode: -1 already exist, registered at "), "New duplicate ecode should cause panic")
+		ExpectTrue(t, strings.Contains(errStr, "ecode_test.go:"), "the panic should name both sites: "+errStr)
 	}()
 	var _ error = New(-1)
 	var _ error = New(-2)
@@ -18,3 +43,786 @@ func TestNew(t *testing.T) {
 func TestStatus(t *testing.T) {
 	Error(0, "this is testing")
 }
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.New(-10001)
+	r.RegisterMessage(-10001, "registry message")
+	r.RegisterHttpCode(-10001, 400)
+	ExpectEQ(t, "registry message", r.Message(c))
+	ExpectEQ(t, 400, r.HttpCode(c))
+	ExpectEQ(t, "-10001", c.Message(), "default registry should not see the code")
+
+	st := c.WithContext(NewRegistryContext(context.TODO(), r))
+	ExpectEQ(t, "registry message", st.Message())
+	ExpectEQ(t, 400, st.HttpCode())
+}
+
+func TestReserveRange(t *testing.T) {
+	r := NewRegistry()
+	billing := r.ReserveRange("billing", -20000, -20999)
+	ExpectEQ(t, Code(-20005), billing.Local(5))
+	ExpectEQ(t, Code(-20010), billing.New(-20010))
+
+	user := r.ReservePrefix("user", 21, 1000)
+	low, high := user.Range()
+	ExpectEQ(t, -21999, low)
+	ExpectEQ(t, -21000, high)
+
+	ExpectEQ(t, "ecode: -21001 out of range [-20999, -20000] of module billing",
+		panicString(func() { billing.New(-21001) }))
+	ExpectEQ(t, "ecode: -20100 is reserved by module billing",
+		panicString(func() { r.New(-20100) }))
+	_, err := r.TryRegisterCode(-20200, 400, "x")
+	ExpectEQ(t, "ecode: -20200 is reserved by module billing", fmt.Sprint(err))
+	ExpectFalse(t, r.exist(-20200))
+	ExpectEQ(t, "ecode: range [-20500, -20001] of module order overlaps [-20999, -20000] of module billing",
+		panicString(func() { r.ReserveRange("order", -20001, -20500) }))
+	r.New(-22005)
+	ExpectEQ(t, "ecode: range [-22999, -22000] of module order contains the registered code -22005",
+		panicString(func() { r.ReserveRange("order", -22000, -22999) }))
+	ExpectEQ(t, 2, len(r.Modules()))
+}
+
+func panicString(f func()) (s string) {
+	defer func() {
+		s = fmt.Sprint(recover())
+	}()
+	f()
+	return
+}
+
+func TestLoadCatalog(t *testing.T) {
+	r := NewRegistry()
+	err := r.LoadCatalog(strings.NewReader(`
+- code: -30001
+  name: UserNotFound
+  message: user not found
+  http_code: 404
+  metadata:
+    owner: account
+- code: -30002
+  message: user disabled
+`), FormatYAML)
+	ExpectNoErr(t, err)
+	ExpectEQ(t, "user not found", r.Message(-30001))
+	ExpectEQ(t, 404, r.HttpCode(-30001))
+	ExpectEQ(t, "user disabled", r.Message(-30002))
+	ExpectEQ(t, http.StatusOK, r.HttpCode(-30002), "http code is left to the default")
+
+	err = r.LoadCatalog(strings.NewReader(`[
+		{"code": -30001, "message": "dup of registered"},
+		{"code": -30003, "name": "A", "message": "a"},
+		{"code": -30003, "name": "A", "message": "b", "http_code": 1000},
+		{"code": 30004}
+	]`), FormatJSON)
+	ExpectErr(t, err)
+	ce, ok := err.(*CatalogError)
+	ExpectTrue(t, ok)
+	ExpectLen(t, 6, ce.Errors, err.Error())
+	ExpectEQ(t, "-30003", r.Message(-30003), "nothing is registered on error")
+
+	r.ReserveRange("billing", -31000, -31999)
+	err = r.LoadCatalog(strings.NewReader(`[{"code": -31005, "message": "in billing"}]`), FormatJSON)
+	ExpectEQ(t, "ecode: 1 error(s) in catalog:\n\tentry #0 (code -31005): reserved by module billing", fmt.Sprint(err))
+}
+
+func TestCatalog(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterCode(-2, 400, "b|c")
+	r.RegisterCode(-1, 404, "a")
+	r.registerEntryInfo(-1, "A", map[string]string{"owner": "x", "docs": "y"})
+
+	entries := r.Catalog()
+	ExpectEQ(t, "ecode_test.go", filepath.Base(entries[0].Source.File))
+	ExpectEQ(t, "github.com/SeaseeYoul/errcode", entries[0].Source.Package)
+	for i := range entries {
+		entries[i].Source = nil
+	}
+	ExpectEQ(t, []CatalogEntry{
+		{Code: -2, Message: "b|c", HttpCode: 400},
+		{Code: -1, Name: "A", Message: "a", HttpCode: 404, Metadata: map[string]string{"owner": "x", "docs": "y"}},
+	}, entries)
+
+	buf := &strings.Builder{}
+	ExpectNoErr(t, EncodeCatalog(buf, entries, FormatCSV))
+	ExpectEQ(t, "code,name,message,http_code,metadata,aliases,deprecated,source\n-2,,b|c,400,,,,\n-1,A,a,404,docs=y;owner=x,,,\n", buf.String())
+
+	buf.Reset()
+	ExpectNoErr(t, EncodeCatalog(buf, entries, FormatMarkdown))
+	ExpectEQ(t, "| Code | Name | Message | HTTP Code | Metadata | Aliases | Deprecated | Source |\n"+
+		"| ---: | --- | --- | ---: | --- | --- | --- | --- |\n"+
+		"| -2 |  | b\\|c | 400 |  |  |  |  |\n"+
+		"| -1 | A | a | 404 | docs=y;owner=x |  |  |  |\n", buf.String())
+
+	for _, format := range []CatalogFormat{FormatJSON, FormatYAML} {
+		buf.Reset()
+		ExpectNoErr(t, EncodeCatalog(buf, entries, format))
+		decoded, err := decodeCatalog(strings.NewReader(buf.String()), format)
+		ExpectNoErr(t, err)
+		ExpectEQ(t, entries, decoded, string(format))
+	}
+}
+
+func TestMessageSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "errcode")
+	ExpectNoErr(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "messages.yaml")
+	ExpectNoErr(t, ioutil.WriteFile(path, []byte("-40001: old message\n"), 0644))
+
+	src, err := NewFileMessageSource(path)
+	ExpectNoErr(t, err)
+	r := NewRegistry()
+	c := r.RegisterCode(-40001, 400, "registered message")
+	r.SetMessageSource(src)
+	ExpectEQ(t, "old message", r.Message(c))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan map[int]string, 2)
+	go func() { _ = src.Watch(ctx, func(m map[int]string) { changed <- m }) }()
+	ExpectEQ(t, map[int]string{-40001: "old message"}, <-changed)
+	go func() { _ = src.Poll(ctx, time.Millisecond, nil) }()
+
+	ExpectNoErr(t, ioutil.WriteFile(path, []byte("-40001: new message\n"), 0644))
+	ExpectNoErr(t, os.Chtimes(path, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+	ExpectEQ(t, map[int]string{-40001: "new message"}, <-changed)
+	ExpectEQ(t, "new message", r.Message(c))
+
+	r.SetMessageSource(nil)
+	ExpectEQ(t, "registered message", r.Message(c))
+}
+
+func TestLocalizedMessage(t *testing.T) {
+	r := NewRegistry()
+	c := r.RegisterCode(-50001, 404, "没找到用户")
+	r.RegisterLocalizedMessages("en", map[int]string{-50001: "user not found"})
+	r.RegisterLocalizedMessages("ja_JP", map[int]string{-50001: "ユーザーが見つかりません"})
+
+	ctx := NewRegistryContext(context.TODO(), r)
+	ExpectEQ(t, "user not found", c.MessageFor(NewLocaleContext(ctx, "en-US")))
+	ExpectEQ(t, "ユーザーが見つかりません", c.MessageFor(NewLocaleContext(ctx, "ja-JP")))
+	ExpectEQ(t, "没找到用户", c.MessageFor(NewLocaleContext(ctx, "fr")))
+	ExpectEQ(t, "没找到用户", c.MessageFor(ctx))
+
+	st := WithLocale(Error(c, "user 1 not found").WithContext(ctx), "en-GB").(*Status)
+	ExpectEQ(t, "user not found", st.Message())
+	// the wire form carries the locale without WithLocalizedMessage.
+	localized := &errdetails.LocalizedMessage{}
+	pb := st.Proto()
+	ExpectNoErr(t, pb.Details[len(pb.Details)-1].UnmarshalTo(localized))
+	ExpectEQ(t, "en-GB", localized.Locale)
+	_, ok := st.LocalizedMessage()
+	ExpectFalse(t, ok, "Proto should not modify the status")
+	ExpectLen(t, len(pb.Details)-1, Error(c, "user 1 not found").WithContext(ctx).(*Status).Proto().Details,
+		"nothing is attached without locale")
+
+	st = st.WithLocalizedMessage()
+	ExpectLen(t, len(pb.Details), st.Proto().Details, "the detail should not be attached twice")
+	localized = &errdetails.LocalizedMessage{}
+	last := st.Proto().Details[len(st.Proto().Details)-1]
+	ExpectNoErr(t, last.UnmarshalTo(localized))
+	ExpectEQ(t, "en-GB", localized.Locale)
+	ExpectEQ(t, "user not found", localized.Message)
+	ExpectLen(t, 1, st.StackEntries())
+}
+
+func TestFreeze(t *testing.T) {
+	r := NewRegistry()
+	c := r.RegisterCode(-60001, 503, "frozen message")
+	r.RegisterLocalizedMessages("en", map[int]string{-60001: "frozen"})
+	r.Freeze()
+	ExpectTrue(t, r.Frozen())
+	ExpectEQ(t, "frozen message", r.Message(c))
+	ExpectEQ(t, "frozen", r.MessageFor(NewLocaleContext(context.TODO(), "en-US"), c))
+	ExpectEQ(t, 503, r.HttpCode(c))
+	ExpectEQ(t, "ecode: registry is frozen", panicString(func() { r.New(-60002) }))
+	ExpectEQ(t, "ecode: registry is frozen", panicString(func() { r.RegisterMessage(-60001, "changed") }))
+	ExpectTrue(t, errors.Is(r.LoadCatalog(strings.NewReader("[]"), FormatJSON), ErrFrozen))
+	_, err := r.TryNew(-60002)
+	ExpectTrue(t, errors.Is(err, ErrFrozen))
+	ExpectTrue(t, errors.Is(r.TryRegisterHttpCode(-60001, 500), ErrFrozen))
+}
+
+func TestFreezeConcurrent(t *testing.T) {
+	r := NewRegistry()
+	const n = 200
+	registered := make([]bool, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registered[i] = r.TryRegisterMessage(-60100-i, "racing") == nil
+		}(i)
+	}
+	r.Freeze()
+	wg.Wait()
+	// a registration is either in the snapshot or rejected.
+	for i, ok := range registered {
+		_, found := r.message(-60100 - i)
+		ExpectEQ(t, ok, found, strconv.Itoa(-60100-i))
+	}
+}
+
+func benchmarkMessage(b *testing.B, frozen bool) {
+	r := NewRegistry()
+	for i := 1; i <= 1000; i++ {
+		r.RegisterCode(code.Code(-i), 400, fmt.Sprintf("message %d", i))
+	}
+	if frozen {
+		r.Freeze()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			c := Code(-(i%1000 + 1))
+			_ = r.Message(c)
+			_ = r.HttpCode(c)
+		}
+	})
+}
+
+func BenchmarkMessageMutex(b *testing.B)  { benchmarkMessage(b, false) }
+func BenchmarkMessageFrozen(b *testing.B) { benchmarkMessage(b, true) }
+
+func TestMeta(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+	c := RegisterCode(-70001, 503, "meta message")
+	RegisterMeta(-70001, Meta{Severity: SeverityWarn, Retryable: true, Owner: "infra", DocsURL: "https://errors.example.com/70001"})
+	ExpectEQ(t, Meta{Severity: SeverityWarn, Retryable: true, Category: FaultServer, Owner: "infra", DocsURL: "https://errors.example.com/70001"}, c.Meta())
+	ExpectEQ(t, FaultClient, InvalidArgument.Meta().Category)
+
+	st := Error(c, "try later")
+	help := &errdetails.Help{}
+	last := st.Proto().Details[len(st.Proto().Details)-1]
+	ExpectNoErr(t, last.UnmarshalTo(help))
+	ExpectEQ(t, "https://errors.example.com/70001", help.Links[0].Url)
+	help, ok := WrapCodes(c).Help()
+	ExpectTrue(t, ok, "WrapCodes should attach the docs url")
+	ExpectEQ(t, "https://errors.example.com/70001", help.Links[0].Url)
+
+	r := NewRegistry()
+	ExpectNoErr(t, r.LoadCatalog(strings.NewReader(`[{"code": -70002, "message": "m", "metadata": {"retryable": "true", "severity": "error", "team": "x"}}]`), FormatJSON))
+	ExpectEQ(t, Meta{Severity: SeverityError, Retryable: true}, r.Meta(-70002))
+	ExpectEQ(t, map[string]string{"retryable": "true", "severity": "error", "team": "x"}, r.Catalog()[0].Metadata)
+	ExpectErr(t, r.LoadCatalog(strings.NewReader(`[{"code": -70003, "message": "m", "metadata": {"severity": "loud"}}]`), FormatJSON))
+}
+
+func TestLookup(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterCode(-90001, 404, "a")
+	r.RegisterCode(-90002, 400, "b")
+	e, ok := r.Lookup(-90001)
+	ExpectTrue(t, ok)
+	ExpectNotNil(t, e.Source)
+	e.Source = nil
+	ExpectEQ(t, CatalogEntry{Code: -90001, Message: "a", HttpCode: 404}, e)
+
+	var codes []int
+	r.Range(func(e CatalogEntry) bool {
+		codes = append(codes, e.Code)
+		return true
+	})
+	ExpectEQ(t, []int{-90002, -90001}, codes)
+
+	r.Alias(-90003, -90001)
+	r.Unregister(-90001)
+	ExpectEQ(t, Code(-90003), r.Resolve(-90003))
+	_, ok = r.Lookup(-90001)
+	ExpectFalse(t, ok)
+	ExpectEQ(t, "-90001", r.Message(-90001))
+	r.RegisterCode(-90001, 404, "again")
+}
+
+func TestConflictPolicy(t *testing.T) {
+	r := NewRegistry()
+	c, err := r.TryNew(-100001)
+	ExpectNoErr(t, err)
+	_, err = r.TryNew(-100001)
+	conflict, ok := err.(*ConflictError)
+	ExpectTrue(t, ok)
+	ExpectEQ(t, ConflictCode, conflict.Kind)
+	ExpectEQ(t, conflict.ExistingSource.Line+2, conflict.IncomingSource.Line)
+	ExpectEQ(t, "github.com/SeaseeYoul/errcode", conflict.IncomingSource.Package)
+
+	r.RegisterMessage(-100001, "first")
+	r.SetConflictPolicy(PolicyKeepFirst)
+	r.RegisterMessage(-100001, "second")
+	ExpectEQ(t, "first", r.Message(c))
+
+	r.SetConflictPolicy(PolicyError)
+	err = r.TryRegisterMessages(map[int]string{-100002: "other", -100001: "third"})
+	ExpectEQ(t, &ConflictError{Kind: ConflictMessage, Code: -100001, Existing: "first", Incoming: "third"}, err)
+	ExpectEQ(t, "-100002", r.Message(-100002), "nothing is registered on conflict")
+	ExpectNoErr(t, r.TryRegisterMessage(-100001, "first"), "same message is not a conflict")
+
+	r.RegisterHttpCode(-100001, 400)
+	ExpectEQ(t, `ecode: http code of -100001 already exist: "400", got "404"`,
+		panicString(func() { r.RegisterHttpCode(-100001, 404) }))
+
+	r.RegisterMessage(-100003, "a")
+	_, err = r.TryRegisterCode(-100003, 400, "b")
+	ExpectEQ(t, ConflictMessage, err.(*ConflictError).Kind)
+	ExpectFalse(t, r.exist(-100003), "the code is not added on conflict")
+	_, err = r.TryRegisterCode(-100003, 400, "a")
+	ExpectNoErr(t, err, "retry should succeed")
+
+	r.SetConflictPolicy(PolicyOverride)
+	r.RegisterHttpCode(-100001, 404)
+	ExpectEQ(t, 404, r.HttpCode(c))
+}
+
+func TestConcurrentRegister(t *testing.T) {
+	r := NewRegistry()
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, err := r.TryRegisterCode(-100100, 400, "concurrent")
+			done <- err
+		}()
+	}
+	var errs int
+	for i := 0; i < 8; i++ {
+		if <-done != nil {
+			errs++
+		}
+	}
+	ExpectEQ(t, 7, errs)
+}
+
+func TestAlias(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+
+	c := RegisterCode(-110001, 404, "renumbered")
+	Alias(-1101, c)
+	Alias(-1102, -1101)
+	ExpectEQ(t, c, String("-1102"))
+	ExpectEQ(t, c, Cause(Code(-1101)))
+	ExpectTrue(t, Equal(Code(-1102), c))
+	ExpectTrue(t, EqualError(c, Code(-1101)))
+	ExpectEQ(t, c, FromProto(&PBStatus{Code: -1101}))
+	pb := &PBStatus{Code: -1101, Message: "old client"}
+	ExpectEQ(t, c.Code(), FromProto(pb).Code())
+	ExpectEQ(t, code.Code(-1101), pb.Code, "the proto is not modified")
+	ExpectEQ(t, "ecode: alias -110001 to -1102 makes a cycle", panicString(func() { Alias(c, -1102) }))
+
+	var used []Code
+	SetDeprecationHook(func(c Code, d Deprecation) { used = append(used, c) })
+	defer SetDeprecationHook(nil)
+	old := RegisterCode(-110002, 400, "old")
+	Deprecate(old, c, "use -110001")
+	Error(old, "first")
+	Errorf(old, "second")
+	ExpectEQ(t, []Code{old}, used)
+
+	paths := []func(c Code){
+		func(c Code) { _ = c.Error() },
+		func(c Code) { FromError(io.EOF, c) },
+		func(c Code) { WrapCodes(c) },
+		func(c Code) { FromProto(&PBStatus{Code: code.Code(c), Message: "from peer"}) },
+	}
+	used = nil
+	for i, use := range paths {
+		d := RegisterCode(code.Code(-110010-i), 400, "deprecated")
+		Deprecate(d, c, "")
+		use(d)
+		ExpectEQ(t, d, used[len(used)-1])
+	}
+	ExpectLen(t, len(paths), used)
+
+	e, _ := Lookup(-110001)
+	ExpectEQ(t, []int{-1101, -1102}, e.Aliases)
+	e, _ = Lookup(-110002)
+	ExpectEQ(t, &Deprecation{Replacement: c, Note: "use -110001"}, e.Deprecated)
+
+	r := NewRegistry()
+	ExpectNoErr(t, r.LoadCatalog(strings.NewReader(`
+- code: -110003
+  message: new
+  aliases: [-1103]
+- code: -110004
+  message: old
+  deprecated: {replacement: -110003, note: renumbered}
+`), FormatYAML))
+	ExpectEQ(t, Code(-110003), r.Resolve(-1103))
+	d, ok := r.Deprecation(-110004)
+	ExpectTrue(t, ok)
+	ExpectEQ(t, Deprecation{Replacement: -110003, Note: "renumbered"}, d)
+}
+
+func TestCodePolicy(t *testing.T) {
+	r := NewRegistry()
+	ExpectEQ(t, "ecode: 1001 is not allowed for business codes", panicString(func() { r.New(1001) }))
+	r.SetCodePolicy(CodePolicy{
+		Canonical: []CodeRange{{0, 16}},
+		Business:  []CodeRange{{math.MinInt32, -1}, {1001, math.MaxInt32}},
+	})
+	ExpectEQ(t, Code(1001), r.New(1001))
+	ExpectEQ(t, Code(1002), r.RegisterCode(1002, 400, "legacy"))
+	ExpectEQ(t, "ecode: 5 is reserved for canonical codes [0, 16]", panicString(func() { r.New(5) }))
+	ExpectEQ(t, "ecode: 500 is not allowed for business codes", panicString(func() { r.New(500) }))
+	legacy := r.ReserveRange("legacy", 2000, 2999)
+	ExpectEQ(t, Code(2001), legacy.Local(1))
+	ExpectEQ(t, "ecode: [900, 1100] is not allowed for business codes",
+		panicString(func() { r.ReserveRange("bad", 900, 1100) }))
+}
+
+type multiError []error
+
+func (m multiError) Error() string   { return fmt.Sprint([]error(m)) }
+func (m multiError) Unwrap() []error { return m }
+
+func TestErrorsIs(t *testing.T) {
+	st := Error(NotFound, "no user")
+	wrapped := fmt.Errorf("load: %w", st)
+	ExpectTrue(t, errors.Is(wrapped, NotFound))
+	ExpectTrue(t, errors.Is(NotFound, st))
+	ExpectTrue(t, errors.Is(wrapped, Error(NotFound, "other message")))
+	ExpectFalse(t, errors.Is(wrapped, Unknown))
+	ExpectFalse(t, errors.Is(Error(OK, "x"), (*Status)(nil)))
+	ExpectFalse(t, errors.Is(OK, (*Status)(nil)))
+
+	var c Code
+	ExpectTrue(t, errors.As(wrapped, &c))
+	ExpectEQ(t, NotFound, c)
+	var s *Status
+	ExpectTrue(t, errors.As(fmt.Errorf("load: %w", Unknown), &s))
+	ExpectEQ(t, Unknown.Code(), s.Code())
+
+	ExpectEQ(t, Codes(st), Cause(wrapped))
+	ExpectTrue(t, EqualError(NotFound, pkgerrors.Wrap(wrapped, "handler")))
+	multi := multiError{errors.New("plain"), fmt.Errorf("deep: %w", Unknown)}
+	ExpectEQ(t, Codes(Unknown), Cause(multi))
+	ExpectTrue(t, EqualError(Unknown, fmt.Errorf("outer: %w", multi)))
+}
+
+type pathError struct{ path string }
+
+func (e *pathError) Error() string { return "open " + e.path }
+
+func TestWrapError(t *testing.T) {
+	cause := &pathError{path: "/etc/app.yaml"}
+	st := Wrap(cause, Internal, "load config failed")
+	ExpectEQ(t, "load config failed", st.Error())
+	ExpectTrue(t, errors.Is(st, cause))
+	ExpectTrue(t, errors.Is(st, Internal))
+	var pe *pathError
+	ExpectTrue(t, errors.As(st, &pe))
+	ExpectEQ(t, "/etc/app.yaml", pe.path)
+	ExpectEQ(t, "open /etc/app.yaml", st.StackEntries()[0].Detail)
+
+	st = Wrapf(cause, Internal, "load %s failed", "config")
+	ExpectEQ(t, "load config failed", st.Error())
+	ExpectEQ(t, error(cause), st.Unwrap())
+
+	st = Errorf(Internal, "load config: %w", cause)
+	ExpectEQ(t, "load config: open /etc/app.yaml", st.Error())
+	ExpectEQ(t, error(cause), st.Unwrap())
+	ExpectTrue(t, errors.Is(st.WithValue("k", "v"), cause))
+	ExpectNil(t, Errorf(Internal, "load %s", "config").Unwrap())
+
+	ExpectTrue(t, errors.Is(FromError(cause, Internal), cause))
+}
+
+func TestFormat(t *testing.T) {
+	st := Error(NotFound, "user not found")
+	ExpectEQ(t, "5: user not found", fmt.Sprintf("%v", st))
+	ExpectEQ(t, "5: user not found", fmt.Sprintf("%s", st))
+	ExpectEQ(t, `"user not found"`, fmt.Sprintf("%q", st))
+	ExpectEQ(t, "load: 5: user not found", fmt.Errorf("load: %w", st).Error())
+	ExpectEQ(t, "<nil>", fmt.Sprintf("%v", (*Status)(nil)))
+	ExpectEQ(t, "<nil>", fmt.Sprintf("%+v", (*Status)(nil)))
+
+	inner := Error(Internal, "db down")
+	st = st.MergeStackEntries(inner)
+	out := fmt.Sprintf("%+v", st)
+	ExpectTrue(t, strings.HasPrefix(out, "5: user not found\nstack: user not found\n\tgithub.com/SeaseeYoul/errcode.TestFormat\n\t\t"), out)
+	ExpectTrue(t, strings.Contains(out, "\nstack: merged from 13: "+Internal.Message()+": db down\n\tgithub.com/SeaseeYoul/errcode.TestFormat\n"), out)
+	ExpectTrue(t, strings.Contains(out, "ecode_test.go:"), out)
+
+	out = fmt.Sprintf("%+v", Wrap(&pathError{path: "/tmp"}, Internal, "load failed"))
+	ExpectTrue(t, strings.HasSuffix(out, "\ncause: open /tmp"), out)
+}
+
+func TestTypedDetails(t *testing.T) {
+	st := Error(Unavailable, "try later").
+		WithRetryInfo(&errdetails.RetryInfo{RetryDelay: durationpb.New(3 * time.Second)}).
+		WithErrorInfo(&errdetails.ErrorInfo{Reason: "OVERLOADED", Domain: "user.api"}).
+		WithBadRequest(&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name"}}})
+
+	retry, ok := st.RetryInfo()
+	ExpectTrue(t, ok)
+	ExpectEQ(t, 3*time.Second, retry.RetryDelay.AsDuration())
+	info, ok := st.ErrorInfo()
+	ExpectTrue(t, ok)
+	ExpectEQ(t, "OVERLOADED", info.Reason)
+	bad, ok := st.BadRequest()
+	ExpectTrue(t, ok)
+	ExpectEQ(t, "name", bad.FieldViolations[0].Field)
+	quota, ok := st.QuotaFailure()
+	ExpectFalse(t, ok)
+	ExpectNil(t, quota)
+	help, ok := (*Status)(nil).Help()
+	ExpectFalse(t, ok)
+	ExpectNil(t, help)
+
+	st = st.WithLocalizedMessageDetail(&errdetails.LocalizedMessage{Locale: "en-US", Message: "try later"})
+	msg, ok := st.LocalizedMessage()
+	ExpectTrue(t, ok)
+	ExpectEQ(t, "en-US", msg.Locale)
+	ExpectLen(t, 1, st.StackEntries())
+}
+
+func TestDetails(t *testing.T) {
+	st := Error(Unavailable, "try later").WithRetryInfo(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)})
+	pb := st.Proto()
+	pb.Details = append(pb.Details, &anypb.Any{TypeUrl: "type.googleapis.com/example.Unknown"})
+	st = FromProto(pb).(*Status)
+	details := st.Details()
+	ExpectLen(t, 4, details)
+	_, ok := details[0].(*errdetails.DebugInfo)
+	ExpectTrue(t, ok)
+	_, ok = details[1].(*Stack)
+	ExpectTrue(t, ok)
+	_, ok = details[2].(*errdetails.RetryInfo)
+	ExpectTrue(t, ok)
+	raw, ok := details[3].(*anypb.Any)
+	ExpectTrue(t, ok)
+	ExpectEQ(t, "type.googleapis.com/example.Unknown", raw.TypeUrl)
+	ExpectTrue(t, details[2] == st.Details()[2], "decoded details should be cached")
+
+	r := NewRegistry()
+	r.SetDetailResolver(new(protoregistry.Types))
+	st = Error(Unavailable, "try later").WithContext(NewRegistryContext(context.Background(), r)).(*Status)
+	_, ok = st.Details()[0].(*anypb.Any)
+	ExpectTrue(t, ok)
+
+	// the details decoded before are not reused by another resolver.
+	st = Error(Unavailable, "try later")
+	_, ok = st.Details()[0].(*errdetails.DebugInfo)
+	ExpectTrue(t, ok)
+	_, ok = st.WithContext(NewRegistryContext(context.Background(), r)).(*Status).Details()[0].(*anypb.Any)
+	ExpectTrue(t, ok)
+	_, ok = st.Details()[0].(*errdetails.DebugInfo)
+	ExpectTrue(t, ok)
+
+	// a resolver which is not comparable.
+	r.SetDetailResolver(mapResolver{Types: new(protoregistry.Types), seen: map[string]bool{}})
+	st = st.WithContext(NewRegistryContext(context.Background(), r)).(*Status)
+	ExpectLen(t, 2, st.Details())
+	ExpectLen(t, 2, st.Details())
+}
+
+type mapResolver struct {
+	*protoregistry.Types
+	seen map[string]bool
+}
+
+func TestStackPolicy(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+
+	SetStackPolicy(StackPolicy{Mode: StackNever})
+	ExpectLen(t, 0, Error(NotFound, "no user").StackEntries())
+	st := Error(NotFound, "no user").WithStack()
+	ExpectLen(t, 1, st.StackEntries())
+	ExpectLen(t, 1, st.WithStack().StackEntries())
+	ExpectTrue(t, strings.Contains(st.StackEntries()[0].StackEntries[0], "ecode_test.go:"))
+
+	cause := errors.New("db: connection refused")
+	for _, st := range []Codes{Wrap(cause, Internal, "failed"), Errorf(Internal, "failed: %w", cause), FromError(cause, Internal)} {
+		debugInfos := st.StackEntries()
+		ExpectLen(t, 1, debugInfos, "the cause is recorded without stack")
+		ExpectEQ(t, "db: connection refused", debugInfos[0].Detail)
+		ExpectLen(t, 0, debugInfos[0].StackEntries)
+	}
+
+	SetStackPolicy(StackPolicy{Mode: StackServerFault})
+	ExpectLen(t, 0, Error(NotFound, "no user").StackEntries())
+	ExpectLen(t, 1, Error(Internal, "db down").StackEntries())
+
+	SetCodeStackPolicy(NotFound, StackPolicy{Mode: StackAlways})
+	ExpectLen(t, 1, Error(NotFound, "no user").StackEntries())
+
+	SetStackPolicy(StackPolicy{Mode: StackSampled, Rate: 0})
+	ExpectLen(t, 0, Error(Internal, "db down").StackEntries())
+	SetStackPolicy(StackPolicy{Mode: StackSampled, Rate: 1})
+	ExpectLen(t, 1, Error(Internal, "db down").StackEntries())
+}
+
+func TestStackDepth(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+
+	SetStackPolicy(StackPolicy{Depth: 1})
+	st := Error(NotFound, "no user")
+	entries := st.StackEntries()[0].StackEntries
+	ExpectLen(t, 1, entries)
+	ExpectTrue(t, strings.HasSuffix(entries[0], " github.com/SeaseeYoul/errcode.TestStackDepth"), entries[0])
+	ExpectEQ(t, "no user", st.StackEntries()[0].Detail)
+
+	st = Error(NotFound, "no user").WithRetryInfo(&errdetails.RetryInfo{})
+	_, ok := st.Details()[0].(*errdetails.DebugInfo)
+	ExpectTrue(t, ok, "the stack should keep its place in the details")
+}
+
+// eagerError is the reference of the stack capture before it is lazy,
+// every frame is symbolized by runtime.Caller and formatted when the status is created.
+func eagerError(c Code, message string) *Status {
+	st := code2Status(c)
+	st.s.Message = message
+	var stackEntries []string
+	for calldepth := 1; ; calldepth++ {
+		pc, file, line, ok := runtime.Caller(calldepth)
+		if !ok {
+			break
+		}
+		function := ""
+		if f := runtime.FuncForPC(pc); f != nil {
+			function = f.Name()
+		}
+		stackEntries = append(stackEntries, fmt.Sprintf("%s:%d %s", file, line, function))
+	}
+	debugInfo, _ := anypb.New(&errdetails.DebugInfo{StackEntries: stackEntries, Detail: message})
+	st.s.Details = append(st.s.Details, debugInfo)
+	return st
+}
+
+func benchmarkError(b *testing.B, p StackPolicy, newError func(c Code, message string) *Status, read bool) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+	SetStackPolicy(p)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		st := newError(NotFound, "no user")
+		if read {
+			_ = st.StackEntries()
+		}
+	}
+}
+
+func BenchmarkErrorNoStack(b *testing.B) {
+	benchmarkError(b, StackPolicy{Mode: StackNever}, Error, false)
+}
+func BenchmarkErrorEagerStack(b *testing.B)     { benchmarkError(b, StackPolicy{}, eagerError, false) }
+func BenchmarkErrorLazyStack(b *testing.B)      { benchmarkError(b, StackPolicy{}, Error, false) }
+func BenchmarkErrorEagerStackRead(b *testing.B) { benchmarkError(b, StackPolicy{}, eagerError, true) }
+func BenchmarkErrorLazyStackRead(b *testing.B)  { benchmarkError(b, StackPolicy{}, Error, true) }
+
+func TestStackOptions(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+
+	SetStackOptions(StackOptions{Filters: []FrameFilter{DropRuntime, DropTesting}, TrimPath: true})
+	entries := Error(NotFound, "no user").StackEntries()[0].StackEntries
+	ExpectLen(t, 1, entries)
+	ExpectTrue(t, strings.HasPrefix(entries[0], "github.com/SeaseeYoul/errcode/ecode_test.go:"), entries[0])
+
+	_, file, _, _ := runtime.Caller(0)
+	mainFrame := runtime.Frame{Function: "main.main", File: path.Join(path.Dir(file), "cmd/foo/main.go")}
+	ExpectEQ(t, "github.com/SeaseeYoul/errcode/cmd/foo/main.go", StackOptions{TrimPath: true}.file(mainFrame))
+
+	SetStackOptions(StackOptions{ModuleOnly: true})
+	entries = Error(NotFound, "no user").StackEntries()[0].StackEntries
+	ExpectLen(t, 1, entries)
+	ExpectTrue(t, strings.HasSuffix(entries[0], " github.com/SeaseeYoul/errcode.TestStackOptions"), entries[0])
+
+	SetStackOptions(StackOptions{Filters: []FrameFilter{DropPackage("github.com/SeaseeYoul/errcode")}})
+	for _, entry := range Error(NotFound, "no user").StackEntries()[0].StackEntries {
+		ExpectFalse(t, strings.Contains(entry, "errcode.TestStackOptions"), entry)
+	}
+}
+
+// yamlStatus is a status created under gopkg.in/yaml.v2, whose function names are escaped by the runtime.
+type yamlStatus struct{ st *Status }
+
+func (y *yamlStatus) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	y.st = Error(NotFound, "no user")
+	return nil
+}
+
+func newYamlStatus(t *testing.T) *Status {
+	y := &yamlStatus{}
+	ExpectNoErr(t, yaml.Unmarshal([]byte("a: 1"), y))
+	return y.st
+}
+
+func TestDottedPackage(t *testing.T) {
+	snap := DefaultRegistry().Snapshot()
+	defer DefaultRegistry().Restore(snap)
+
+	ExpectEQ(t, "gopkg.in/yaml.v2", funcPackage("gopkg.in/yaml%2ev2.Unmarshal"))
+	ExpectEQ(t, "gopkg.in/yaml.v2", funcPackage("gopkg.in/yaml%2ev2.(*decoder).unmarshal"))
+	found := false
+	for _, frame := range newYamlStatus(t).Frames() {
+		found = found || frame.Package == "gopkg.in/yaml.v2"
+	}
+	ExpectTrue(t, found, "the package of yaml frames should be unescaped")
+
+	SetStackOptions(StackOptions{TrimPath: true})
+	found = false
+	for _, entry := range newYamlStatus(t).StackEntries()[0].StackEntries {
+		found = found || strings.HasPrefix(entry, "gopkg.in/yaml.v2/")
+	}
+	ExpectTrue(t, found, "the files of yaml should be trimmed by the unescaped package")
+
+	SetStackOptions(StackOptions{Filters: []FrameFilter{DropPackage("gopkg.in/yaml.v2")}})
+	for _, entry := range newYamlStatus(t).StackEntries()[0].StackEntries {
+		ExpectFalse(t, strings.Contains(entry, "gopkg.in/yaml"), entry)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	st := Error(NotFound, "no user")
+	frames := st.Frames()
+	ExpectEQ(t, "github.com/SeaseeYoul/errcode.TestFrames", frames[0].Function)
+	ExpectEQ(t, "github.com/SeaseeYoul/errcode", frames[0].Package)
+	ExpectTrue(t, strings.HasSuffix(frames[0].File, "ecode_test.go"), frames[0].File)
+	ExpectGT(t, frames[0].Line, 0)
+	ExpectLen(t, len(st.StackEntries()[0].StackEntries), frames)
+	ExpectEQ(t, "no user", st.Stacks()[0].Detail)
+
+	st = st.MergeStackEntries(Error(Internal, "db down"))
+	ExpectLen(t, 2, st.Stacks())
+
+	// a status from a peer which only send DebugInfo.
+	pb := &PBStatus{Code: code.Code(NotFound), Message: "no user"}
+	debugInfo, _ := anypb.New(&errdetails.DebugInfo{StackEntries: []string{"/src/app/user.go:42 github.com/me/app.(*Service).Get"}})
+	pb.Details = append(pb.Details, debugInfo)
+	ExpectEQ(t, []Frame{{Function: "github.com/me/app.(*Service).Get", File: "/src/app/user.go", Line: 42, Package: "github.com/me/app"}},
+		FromProto(pb).(*Status).Frames())
+}
+
+func TestImmutableStatus(t *testing.T) {
+	sentinel := Error(Unavailable, "try later")
+	ExpectLen(t, 2, sentinel.Details())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			st := sentinel.WithErrorInfo(&errdetails.ErrorInfo{Reason: strconv.Itoa(i)})
+			st = st.MergeStackEntries(sentinel).WithStackEntries("annotated")
+			st = st.WithContext(context.Background()).(*Status)
+			info, ok := st.ErrorInfo()
+			ExpectTrue(t, ok)
+			ExpectEQ(t, strconv.Itoa(i), info.Reason)
+			_ = sentinel.Details()
+			_ = fmt.Sprintf("%+v", sentinel)
+			pb := sentinel.Proto()
+			pb.Details = pb.Details[:0]
+		}(i)
+	}
+	wg.Wait()
+	ExpectLen(t, 2, sentinel.Details())
+	ExpectLen(t, 2, sentinel.Proto().Details)
+	_, ok := sentinel.ErrorInfo()
+	ExpectFalse(t, ok)
+}
@@ -2,79 +2,40 @@ package errcode
 
 import (
 	"context"
-	"fmt"
-	"github.com/pkg/errors"
 	"google.golang.org/genproto/googleapis/rpc/code"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
-	"net/http"
 	"strconv"
-	"sync"
 	"time"
 )
 
-var (
-	_messages    = map[int]string{}
-	_mxMessages  = &sync.RWMutex{}
-	_httpCodes   = map[int]int{}
-	_mxHttpCodes = &sync.RWMutex{}
-	_codes       = map[int]struct{}{} // register codes.
-)
-
 func RegisterMessages(cm map[int]string) {
-	_mxMessages.Lock()
-	defer _mxMessages.Unlock()
-	for k, v := range cm {
-		_messages[k] = v
-	}
+	_defaultRegistry.RegisterMessages(cm)
 }
 
 func RegisterMessage(code int, message string) {
-	_mxMessages.Lock()
-	defer _mxMessages.Unlock()
-	_messages[code] = message
+	_defaultRegistry.RegisterMessage(code, message)
 }
 
 func RegisterHttpCode(code int, httpCode int) {
-	_mxHttpCodes.Lock()
-	defer _mxHttpCodes.Unlock()
-	_httpCodes[code] = httpCode
+	_defaultRegistry.RegisterHttpCode(code, httpCode)
 }
 
 // New a errcode.Codes by int value.
 // NOTE: errcode must unique in global, the New will check repeat and then panic.
 func New(e int) Code {
-	if e > 0 {
-		panic("business ecode must less than zero")
-	}
-	return add(e)
-}
-
-func add(e int) Code {
-	if _, ok := _codes[e]; ok {
-		panic(fmt.Sprintf("ecode: %d already exist", e))
-	}
-	_codes[e] = struct{}{}
-	return Int(e)
+	return _defaultRegistry.New(e)
 }
 
 func RegisterCode(c code.Code, httpCode int, message string) Code {
-	if c > 0 {
-		panic("business ecode must less than zero")
-	}
-	return addCode(c, httpCode, message)
+	return _defaultRegistry.RegisterCode(c, httpCode, message)
 }
 
 func addCode(c code.Code, httpCode int, message string) Code {
-	i := int(c)
-	RegisterMessage(i, message)
-	RegisterHttpCode(i, httpCode)
-	return add(i)
+	return mustCode(_defaultRegistry.addInt(int(c), httpCode, message))
 }
 
 func addInt(c int, httpCode int, message string) Code {
-	RegisterMessage(c, message)
-	RegisterHttpCode(c, httpCode)
-	return add(c)
+	return mustCode(_defaultRegistry.addInt(c, httpCode, message))
 }
 
 // Codes errcode error interface which has a code & message.
@@ -136,14 +97,8 @@ func (e Code) Context() context.Context {
 
 //
 func (e Code) Error() string {
-	{
-		_mxMessages.RLock()
-		defer _mxMessages.RUnlock()
-		if msg, ok := _messages[e.Code()]; ok {
-			return msg
-		}
-	}
-	return strconv.FormatInt(int64(e), 10)
+	_defaultRegistry.use(e)
+	return _defaultRegistry.Message(e)
 }
 
 // Code return error code
@@ -154,17 +109,17 @@ func (e Code) Message() string {
 	return e.Error()
 }
 
+// MessageFor return message in the locale carried by ctx,
+// it is resolved against the registry carried by ctx.
+func (e Code) MessageFor(ctx context.Context) string {
+	return RegistryFromContext(ctx).MessageFor(ctx, e)
+}
+
 // Details return details.
 func (e Code) Details() []interface{} { return nil }
 
 func (e Code) HttpCode() int {
-	_mxHttpCodes.RLock()
-	defer _mxHttpCodes.RUnlock()
-	if httpCode, ok := _httpCodes[e.Code()]; ok {
-		return httpCode
-	} else {
-		return http.StatusOK
-	}
+	return _defaultRegistry.HttpCode(e)
 }
 
 func (e Code) StackEntries() (details []*errdetails.DebugInfo) {
@@ -174,7 +129,7 @@ func (e Code) StackEntries() (details []*errdetails.DebugInfo) {
 // Int parse code int to error.
 func Int(i int) Code { return Code(i) }
 
-// String parse code string to error.
+// String parse code string to error, aliases are resolved against the default registry.
 func String(e string) Code {
 	if e == "" {
 		return OK
@@ -184,21 +139,69 @@ func String(e string) Code {
 	if err != nil {
 		return InvalidArgument
 	}
-	return Code(i)
+	return _defaultRegistry.Resolve(Code(i))
 }
 
-// Cause cause from error to ecode.
+// Cause cause from error to ecode, aliases of Code are resolved against the default registry.
+// It walks Cause() of github.com/pkg/errors and Unwrap() of the standard errors, including Unwrap() []error.
 func Cause(e error) Codes {
 	if e == nil {
 		return OK
 	}
-	ec, ok := errors.Cause(e).(Codes)
+	ec, ok := findCodes(e)
 	if ok {
+		if c, ok := ec.(Code); ok {
+			return _defaultRegistry.Resolve(c)
+		}
 		return ec
 	}
 	return String(e.Error())
 }
 
+// findCodes return the first Codes in the chain of e, depth first.
+func findCodes(e error) (Codes, bool) {
+	for e != nil {
+		if ec, ok := e.(Codes); ok {
+			return ec, true
+		}
+		switch x := e.(type) {
+		case interface{ Cause() error }:
+			e = x.Cause()
+		case interface{ Unwrap() error }:
+			e = x.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range x.Unwrap() {
+				if ec, ok := findCodes(err); ok {
+					return ec, true
+				}
+			}
+			return nil, false
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// Is report whether target is a Codes with the same code, so errors.Is(err, NotFound) works.
+// A nil target never matches, even though a nil Codes is taken as OK elsewhere.
+func (e Code) Is(target error) bool {
+	if CheckIsNil(target) {
+		return false
+	}
+	c, ok := target.(Codes)
+	return ok && Equal(e, c)
+}
+
+// As set target to a *Status of the code if target is a **Status.
+func (e Code) As(target interface{}) bool {
+	if st, ok := target.(**Status); ok {
+		*st = e.toStatus()
+		return true
+	}
+	return false
+}
+
 //safeCode if c == nil, may use OK instead
 func safeCode(c Codes) Codes {
 	if CheckIsNil(c) {
@@ -207,14 +210,14 @@ func safeCode(c Codes) Codes {
 	return c
 }
 
-// Equal equal a and b by code int.
+// Equal equal a and b by code int, aliases are resolved against the default registry.
 func Equal(a, b Codes) bool {
-	return safeCode(a).Code() == safeCode(b).Code()
+	return _defaultRegistry.Resolve(Code(safeCode(a).Code())) == _defaultRegistry.Resolve(Code(safeCode(b).Code()))
 }
 
 // EqualError equal error
 func EqualError(code Codes, err error) bool {
-	return Cause(err).Code() == code.Code()
+	return Equal(code, Cause(err))
 }
 
 // CheckOk Check whether to be OK
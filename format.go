@@ -0,0 +1,97 @@
+package errcode
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"io"
+	"strings"
+)
+
+var _ fmt.Formatter = &Status{}
+
+// Format implement fmt.Formatter.
+//
+//	%s, %v  code and message
+//	%q      quoted message
+//	%+v     code, message, details and the stack of every DebugInfo, merged ones included
+//
+// NOTE: the code is printed by %v and %w too, fmt.Errorf("load: %w", st) is "load: 5: no user",
+// use st.Error() for the message only. A nil status is printed as <nil>.
+func (s *Status) Format(f fmt.State, verb rune) {
+	if s == nil {
+		fmt.Fprint(f, "<nil>")
+		return
+	}
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			s.formatVerbose(f)
+			return
+		}
+		fmt.Fprintf(f, "%d: %s", s.Code(), s.Error())
+	case 's':
+		fmt.Fprintf(f, "%d: %s", s.Code(), s.Error())
+	case 'q':
+		fmt.Fprintf(f, "%q", s.Error())
+	default:
+		fmt.Fprintf(f, "%%!%c(*errcode.Status=%d: %s)", verb, s.Code(), s.Error())
+	}
+}
+
+func (s *Status) formatVerbose(w io.Writer) {
+	fmt.Fprintf(w, "%d: %s", s.Code(), s.Error())
+	if s.s == nil {
+		return
+	}
+	s.symbolize()
+	for _, any := range s.s.Details {
+		if any.MessageIs(&Stack{}) {
+			// it is written by the DebugInfo of the same stack.
+			continue
+		}
+		debugInfo := &errdetails.DebugInfo{}
+		if any.MessageIs(debugInfo) {
+			if err := any.UnmarshalTo(debugInfo); err == nil {
+				writeDebugInfo(w, debugInfo)
+				continue
+			}
+		}
+		if m, err := any.UnmarshalNew(); err == nil {
+			fmt.Fprintf(w, "\n%s: %v", m.ProtoReflect().Descriptor().FullName(), m)
+		} else {
+			fmt.Fprintf(w, "\n%s", any.TypeUrl)
+		}
+	}
+	if s.cause != nil {
+		fmt.Fprintf(w, "\ncause: %+v", s.cause)
+	}
+}
+
+// writeDebugInfo write the detail then the stack entries as function and file:line in the next line.
+func writeDebugInfo(w io.Writer, debugInfo *errdetails.DebugInfo) {
+	fmt.Fprintf(w, "\nstack: %s", debugDetail(debugInfo.Detail))
+	for _, entry := range debugInfo.StackEntries {
+		i := strings.LastIndexByte(entry, ' ')
+		if i < 0 {
+			fmt.Fprintf(w, "\n\t%s", entry)
+			continue
+		}
+		fmt.Fprintf(w, "\n\t%s\n\t\t%s", entry[i+1:], entry[:i])
+	}
+}
+
+// debugDetail make the detail written by MergeStackEntries readable.
+func debugDetail(detail string) string {
+	if !strings.HasPrefix(detail, ":") {
+		return detail
+	}
+	parts := strings.SplitN(detail[1:], "|", 3)
+	if len(parts) != 3 {
+		return detail
+	}
+	merged := "merged from " + parts[0] + ": " + parts[1]
+	if parts[2] != "" {
+		merged += ": " + parts[2]
+	}
+	return merged
+}
@@ -0,0 +1,223 @@
+package errcode
+
+import (
+	"path"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Frame is a frame of a captured stack.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Package  string
+}
+
+// Frames return the frames of the stack captured for the status,
+// they are parsed from the errdetails.DebugInfo if the status comes without Stack.
+func (s *Status) Frames() []Frame {
+	if stacks := s.Stacks(); len(stacks) > 0 {
+		frames := make([]Frame, 0, len(stacks[0].Frames))
+		for _, f := range stacks[0].Frames {
+			frames = append(frames, Frame{
+				Function: f.Function,
+				File:     f.File,
+				Line:     int(f.Line),
+				Package:  f.Package,
+			})
+		}
+		return frames
+	}
+	debugInfos := s.StackEntries()
+	if len(debugInfos) == 0 {
+		return nil
+	}
+	frames := make([]Frame, 0, len(debugInfos[0].StackEntries))
+	for _, entry := range debugInfos[0].StackEntries {
+		frames = append(frames, parseStackEntry(entry))
+	}
+	return frames
+}
+
+// Stacks return every Stack, the merged ones included.
+func (s *Status) Stacks() []*Stack {
+	if s == nil || s.s == nil {
+		return nil
+	}
+	s.symbolize()
+	var stacks []*Stack
+	for _, any := range s.s.Details {
+		stack := &Stack{}
+		if !any.MessageIs(stack) {
+			continue
+		}
+		if err := any.UnmarshalTo(stack); err != nil {
+			continue
+		}
+		stacks = append(stacks, stack)
+	}
+	return stacks
+}
+
+// parseStackEntry parse a stack entry of errdetails.DebugInfo like "file:line function".
+func parseStackEntry(entry string) Frame {
+	var frame Frame
+	if i := strings.LastIndexByte(entry, ' '); i >= 0 {
+		frame.Function = entry[i+1:]
+		frame.Package = funcPackage(frame.Function)
+		entry = entry[:i]
+	}
+	frame.File = entry
+	if i := strings.LastIndexByte(entry, ':'); i >= 0 {
+		if line, err := strconv.Atoi(entry[i+1:]); err == nil {
+			frame.File, frame.Line = entry[:i], line
+		}
+	}
+	return frame
+}
+
+// FrameFilter report whether the frame should be dropped from the stack entries.
+type FrameFilter func(frame runtime.Frame) bool
+
+var (
+	// DropRuntime drop the frames of package runtime.
+	DropRuntime = DropPackage("runtime")
+	// DropTesting drop the frames of package testing.
+	DropTesting = DropPackage("testing")
+)
+
+// DropPackage drop the frames of the packages with one of prefixes as path prefix,
+// e.g. "github.com/me/app/middleware" drop the frames of middleware and its sub packages.
+func DropPackage(prefixes ...string) FrameFilter {
+	return func(frame runtime.Frame) bool {
+		pkg := funcPackage(frame.Function)
+		for _, prefix := range prefixes {
+			if pkg == prefix || strings.HasPrefix(pkg, strings.TrimSuffix(prefix, "/")+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StackOptions decide how the captured stacks are turned into stack entries.
+type StackOptions struct {
+	// Filters drop the frames for which any of them return true.
+	Filters []FrameFilter
+	// TrimPath replace the build path of the file by the package path, which is relative to the module root or GOPATH,
+	// e.g. /home/me/src/app/user/user.go become github.com/me/app/user/user.go.
+	// Files of package main are trimmed by the root of the main module once a package of it is seen in a stack.
+	TrimPath bool
+	// ModuleOnly cut the stack at the first frame outside the module of the caller.
+	ModuleOnly bool
+}
+
+var _stackOptions atomic.Value // StackOptions
+
+// SetStackOptions set how the stacks are turned into stack entries, it applies to the stacks not yet symbolized.
+// The stack options are process wide as the stack policy, see SetStackPolicy.
+func SetStackOptions(opts StackOptions) {
+	opts.Filters = append([]FrameFilter(nil), opts.Filters...)
+	_stackOptions.Store(opts)
+}
+
+// loadStackOptions return the stack options, the zero value keep every frame as it is.
+func loadStackOptions() StackOptions {
+	opts, _ := _stackOptions.Load().(StackOptions)
+	return opts
+}
+
+// keep report whether frame is kept by the filters.
+func (opts StackOptions) keep(frame runtime.Frame) bool {
+	for _, filter := range opts.Filters {
+		if filter(frame) {
+			return false
+		}
+	}
+	return true
+}
+
+// file return the file of frame, trimmed if TrimPath is set.
+func (opts StackOptions) file(frame runtime.Frame) string {
+	if !opts.TrimPath {
+		return frame.File
+	}
+	pkg := funcPackage(frame.Function)
+	switch pkg {
+	case "":
+		return frame.File
+	case "main":
+		// the import path of a main package is unknown, its file is trimmed by the root of the main module.
+		mod := mainModule()
+		root, _ := _mainModuleRoot.Load().(string)
+		if mod != "" && root != "" && strings.HasPrefix(frame.File, root+"/") {
+			return mod + frame.File[len(root):]
+		}
+		return frame.File
+	}
+	learnMainModuleRoot(pkg, frame.File)
+	return pkg + "/" + path.Base(frame.File)
+}
+
+// learnMainModuleRoot remember the root directory of the main module from the file of a package in it.
+func learnMainModuleRoot(pkg, file string) {
+	mod := mainModule()
+	if mod == "" || (pkg != mod && !strings.HasPrefix(pkg, mod+"/")) {
+		return
+	}
+	if root, _ := _mainModuleRoot.Load().(string); root != "" {
+		return
+	}
+	dir, rel := path.Dir(file), strings.TrimPrefix(pkg, mod)
+	if strings.HasSuffix(dir, rel) {
+		_mainModuleRoot.Store(strings.TrimSuffix(dir, rel))
+	}
+}
+
+var (
+	_modulesOnce    sync.Once
+	_mainModule     string
+	_modules        []string     // module paths, the longest first.
+	_mainModuleRoot atomic.Value // string, the directory of the main module.
+)
+
+func loadModules() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	_mainModule = info.Main.Path
+	if _mainModule != "" {
+		_modules = append(_modules, _mainModule)
+	}
+	for _, dep := range info.Deps {
+		_modules = append(_modules, dep.Path)
+	}
+	sort.Slice(_modules, func(i, j int) bool { return len(_modules[i]) > len(_modules[j]) })
+}
+
+func mainModule() string {
+	_modulesOnce.Do(loadModules)
+	return _mainModule
+}
+
+// moduleOf return the module of the function, it is empty for the standard library and unknown modules.
+func moduleOf(function string) string {
+	_modulesOnce.Do(loadModules)
+	pkg := funcPackage(function)
+	if pkg == "main" {
+		return _mainModule
+	}
+	for _, mod := range _modules {
+		if pkg == mod || strings.HasPrefix(pkg, mod+"/") {
+			return mod
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package errcode
+
+import (
+	"errors"
+)
+
+// ErrFrozen is returned by the registration of a frozen registry, see Registry.Freeze.
+var ErrFrozen = errors.New("ecode: registry is frozen")
+
+// frozenTables is the tables published by Freeze, they are never modified.
+type frozenTables struct {
+	messages     map[int]string
+	localized    map[string]map[int]string
+	httpCodes    map[int]int
+	metas        map[int]Meta
+	aliases      map[int]int
+	deprecations map[int]*deprecation
+}
+
+// Freeze freeze the default registry.
+func Freeze() {
+	_defaultRegistry.Freeze()
+}
+
+// Freeze publish an immutable snapshot of the messages and http codes, lookups are lock-free after it.
+// It should be called after init, later registration panics.
+// NOTE: the message source is still consulted and can be swapped.
+func (r *Registry) Freeze() {
+	r.lockTables()
+	defer r.unlockTables()
+	snap := &frozenTables{}
+	snap.messages, snap.localized = copyMessageTables(r.messages, r.localized)
+	snap.httpCodes = copyHttpCodes(r.httpCodes)
+	_, _, snap.metas = copyInfo(nil, nil, r.metas)
+	snap.aliases, snap.deprecations = copyAliases(r.aliases, r.deprecations)
+	r.frozen.Store(snap)
+}
+
+// lockTables lock every table for writing.
+// Registration checks frozen under the lock of the table it writes,
+// so a registration racing with Freeze is either in the snapshot or rejected.
+func (r *Registry) lockTables() {
+	r.mxCodes.Lock()
+	r.mxModules.Lock()
+	r.mxMessages.Lock()
+	r.mxHttpCodes.Lock()
+	r.mxInfo.Lock()
+}
+
+func (r *Registry) unlockTables() {
+	r.mxInfo.Unlock()
+	r.mxHttpCodes.Unlock()
+	r.mxMessages.Unlock()
+	r.mxModules.Unlock()
+	r.mxCodes.Unlock()
+}
+
+// Frozen report whether the registry is frozen.
+func (r *Registry) Frozen() bool {
+	return r.snapshot() != nil
+}
+
+func (r *Registry) snapshot() *frozenTables {
+	snap, _ := r.frozen.Load().(*frozenTables)
+	return snap
+}
+
+// checkFrozen is called under the lock of the table to write, see lockTables.
+func (r *Registry) checkFrozen() error {
+	if r.Frozen() {
+		return ErrFrozen
+	}
+	return nil
+}
+
+func (r *Registry) message(code int) (msg string, ok bool) {
+	if snap := r.snapshot(); snap != nil {
+		msg, ok = snap.messages[code]
+		return
+	}
+	r.mxMessages.RLock()
+	msg, ok = r.messages[code]
+	r.mxMessages.RUnlock()
+	return
+}
+
+func (r *Registry) localizedMessage(locale string, code int) (msg string, ok bool) {
+	if snap := r.snapshot(); snap != nil {
+		msg, ok = snap.localized[locale][code]
+		return
+	}
+	r.mxMessages.RLock()
+	msg, ok = r.localized[locale][code]
+	r.mxMessages.RUnlock()
+	return
+}
+
+func (r *Registry) httpCode(code int) (httpCode int, ok bool) {
+	if snap := r.snapshot(); snap != nil {
+		httpCode, ok = snap.httpCodes[code]
+		return
+	}
+	r.mxHttpCodes.RLock()
+	httpCode, ok = r.httpCodes[code]
+	r.mxHttpCodes.RUnlock()
+	return
+}
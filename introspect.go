@@ -0,0 +1,201 @@
+package errcode
+
+// Lookup return the catalog entry of a registered code in the default registry.
+func Lookup(c int) (CatalogEntry, bool) {
+	return _defaultRegistry.Lookup(c)
+}
+
+// Range call fn for every code of the default registry.
+func Range(fn func(e CatalogEntry) bool) {
+	_defaultRegistry.Range(fn)
+}
+
+// Unregister remove code from the default registry.
+func Unregister(c int) {
+	_defaultRegistry.Unregister(c)
+}
+
+// Lookup return the catalog entry of a registered code.
+func (r *Registry) Lookup(c int) (CatalogEntry, bool) {
+	if !r.exist(c) {
+		return CatalogEntry{}, false
+	}
+	entries := []CatalogEntry{{Code: c}}
+	r.fillEntries(entries)
+	return entries[0], true
+}
+
+// Range call fn for every code sorted by code, it stops if fn return false.
+// fn works on a snapshot, so it is safe to register in fn.
+func (r *Registry) Range(fn func(e CatalogEntry) bool) {
+	for _, e := range r.Catalog() {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+// Unregister remove code with its messages, http code, metadata, deprecation and the aliases from or to it,
+// then it can be registered again.
+// It is mostly used by tests.
+func (r *Registry) Unregister(c int) {
+	r.lockTables()
+	defer r.unlockTables()
+	must(r.checkFrozen())
+	delete(r.codes, c)
+	delete(r.sources, c)
+	delete(r.messages, c)
+	for _, messages := range r.localized {
+		delete(messages, c)
+	}
+	delete(r.httpCodes, c)
+	delete(r.names, c)
+	delete(r.metadata, c)
+	delete(r.metas, c)
+	delete(r.aliases, c)
+	for old, to := range r.aliases {
+		if to == c {
+			delete(r.aliases, old)
+		}
+	}
+	delete(r.deprecations, c)
+}
+
+// Snapshot is a copy of the whole state of a registry, see Registry.Snapshot.
+type Snapshot struct {
+	codes        map[int]struct{}
+	sources      map[int]Source
+	messages     map[int]string
+	localized    map[string]map[int]string
+	httpCodes    map[int]int
+	modules      []*Module
+	names        map[int]string
+	metadata     map[int]map[string]string
+	metas        map[int]Meta
+	aliases      map[int]int
+	deprecations map[int]*deprecation
+	frozen       *frozenTables
+	policy       ConflictPolicy
+	codePolicy   CodePolicy
+	stack        *stackSettings // only in the snapshot of the default registry.
+}
+
+// Snapshot copy the state of the registry, it can be put back by Restore.
+// The message source, detail resolver and deprecation hook are not included.
+// The process wide stack policy and options are included in the snapshot of the default registry.
+func (r *Registry) Snapshot() *Snapshot {
+	s := &Snapshot{
+		frozen:     r.snapshot(),
+		policy:     r.ConflictPolicy(),
+		codePolicy: r.CodePolicy(),
+	}
+	if r == _defaultRegistry {
+		s.stack = saveStackSettings()
+	}
+	r.mxCodes.RLock()
+	s.codes, s.sources = copyCodes(r.codes, r.sources)
+	r.mxCodes.RUnlock()
+
+	r.mxMessages.RLock()
+	s.messages, s.localized = copyMessageTables(r.messages, r.localized)
+	r.mxMessages.RUnlock()
+
+	r.mxHttpCodes.RLock()
+	s.httpCodes = copyHttpCodes(r.httpCodes)
+	r.mxHttpCodes.RUnlock()
+
+	r.mxModules.RLock()
+	s.modules = append([]*Module(nil), r.modules...)
+	r.mxModules.RUnlock()
+
+	r.mxInfo.RLock()
+	s.names, s.metadata, s.metas = copyInfo(r.names, r.metadata, r.metas)
+	s.aliases, s.deprecations = copyAliases(r.aliases, r.deprecations)
+	r.mxInfo.RUnlock()
+	return s
+}
+
+// Restore put the state saved by Snapshot back, codes registered after the snapshot are removed.
+// A snapshot can be restored more than once.
+func (r *Registry) Restore(s *Snapshot) {
+	r.mxCodes.Lock()
+	r.codes, r.sources = copyCodes(s.codes, s.sources)
+	r.mxCodes.Unlock()
+
+	r.mxMessages.Lock()
+	r.messages, r.localized = copyMessageTables(s.messages, s.localized)
+	r.mxMessages.Unlock()
+
+	r.mxHttpCodes.Lock()
+	r.httpCodes = copyHttpCodes(s.httpCodes)
+	r.mxHttpCodes.Unlock()
+
+	r.mxModules.Lock()
+	r.modules = append([]*Module(nil), s.modules...)
+	r.mxModules.Unlock()
+
+	r.mxInfo.Lock()
+	r.names, r.metadata, r.metas = copyInfo(s.names, s.metadata, s.metas)
+	r.aliases, r.deprecations = copyAliases(s.aliases, s.deprecations)
+	r.mxInfo.Unlock()
+
+	r.SetConflictPolicy(s.policy)
+	r.codePolicy.Store(s.codePolicy)
+	if r == _defaultRegistry && s.stack != nil {
+		s.stack.restore()
+	}
+	r.frozen.Store(s.frozen)
+}
+
+func copyCodes(codes map[int]struct{}, sources map[int]Source) (map[int]struct{}, map[int]Source) {
+	m := make(map[int]struct{}, len(codes))
+	for k := range codes {
+		m[k] = struct{}{}
+	}
+	s := make(map[int]Source, len(sources))
+	for k, v := range sources {
+		s[k] = v
+	}
+	return m, s
+}
+
+func copyMessageTables(messages map[int]string, localized map[string]map[int]string) (map[int]string, map[string]map[int]string) {
+	l := make(map[string]map[int]string, len(localized))
+	for locale, m := range localized {
+		l[locale] = copyMessages(m)
+	}
+	return copyMessages(messages), l
+}
+
+func copyHttpCodes(httpCodes map[int]int) map[int]int {
+	m := make(map[int]int, len(httpCodes))
+	for k, v := range httpCodes {
+		m[k] = v
+	}
+	return m
+}
+
+func copyInfo(names map[int]string, metadata map[int]map[string]string, metas map[int]Meta) (
+	map[int]string, map[int]map[string]string, map[int]Meta) {
+	md := make(map[int]map[string]string, len(metadata))
+	for k, v := range metadata {
+		md[k] = v // metadata of a code is never modified after registered.
+	}
+	ms := make(map[int]Meta, len(metas))
+	for k, v := range metas {
+		ms[k] = v
+	}
+	return copyMessages(names), md, ms
+}
+
+func copyAliases(aliases map[int]int, deprecations map[int]*deprecation) (map[int]int, map[int]*deprecation) {
+	a := make(map[int]int, len(aliases))
+	for k, v := range aliases {
+		a[k] = v
+	}
+	d := make(map[int]*deprecation, len(deprecations))
+	for k, v := range deprecations {
+		d[k] = v // shared, so a deprecation is reported once.
+	}
+	return a, d
+}
@@ -0,0 +1,83 @@
+package errcode
+
+import (
+	"context"
+	"strings"
+)
+
+type localeKey struct{}
+
+// NewLocaleContext return a copy of ctx which carries locale, e.g. "en-US", "zh-Hant-TW".
+func NewLocaleContext(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext return the locale carried by ctx.
+func LocaleFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	locale, ok := ctx.Value(localeKey{}).(string)
+	return locale, ok && locale != ""
+}
+
+// WithLocale create a new Codes whose context carries locale.
+func WithLocale(e Codes, locale string) Codes {
+	return WithValue(e, localeKey{}, locale)
+}
+
+// RegisterLocalizedMessages register messages of locale in the default registry.
+func RegisterLocalizedMessages(locale string, cm map[int]string) {
+	_defaultRegistry.RegisterLocalizedMessages(locale, cm)
+}
+
+// normalizeLocale turn "zh_Hant_TW" into "zh-hant-tw", locales are matched case-insensitively.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+// parentLocale return the parent of locale, e.g. "zh-hant" of "zh-hant-tw", "" if it has none.
+func parentLocale(locale string) string {
+	if i := strings.LastIndex(locale, "-"); i > 0 {
+		return locale[:i]
+	}
+	return ""
+}
+
+// RegisterLocalizedMessages register messages of locale.
+func (r *Registry) RegisterLocalizedMessages(locale string, cm map[int]string) {
+	locale = normalizeLocale(locale)
+	r.mxMessages.Lock()
+	defer r.mxMessages.Unlock()
+	must(r.checkFrozen())
+	messages, ok := r.localized[locale]
+	if !ok {
+		messages = map[int]string{}
+		r.localized[locale] = messages
+	}
+	for k, v := range cm {
+		messages[k] = v
+	}
+}
+
+// LocalizedMessage return the message of code in locale, it falls back through the parent locales,
+// e.g. "zh-Hant-TW", "zh-Hant" then "zh".
+func (r *Registry) LocalizedMessage(c Code, locale string) (string, bool) {
+	for l := normalizeLocale(locale); l != ""; l = parentLocale(l) {
+		if msg, ok := r.localizedMessage(l, c.Code()); ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// MessageFor return the message of code in the locale carried by ctx,
+// or the default message if the locale has none.
+func (r *Registry) MessageFor(ctx context.Context, c Code) string {
+	if locale, ok := LocaleFromContext(ctx); ok {
+		if msg, ok := r.LocalizedMessage(c, locale); ok {
+			return msg
+		}
+	}
+	return r.Message(c)
+}
@@ -0,0 +1,198 @@
+package errcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MessageSource provide messages of codes, e.g. a config center.
+// Messages from the source take precedence over the registered ones.
+type MessageSource interface {
+	// Get return the message of code, it is called on every lookup and must be cheap.
+	Get(code int) (string, bool)
+	// Watch call fn with the current message table and then on every change.
+	// It blocks until ctx is done or the source fails.
+	Watch(ctx context.Context, fn func(messages map[int]string)) error
+}
+
+// SetMessageSource set the message source of the default registry.
+func SetMessageSource(src MessageSource) {
+	_defaultRegistry.SetMessageSource(src)
+}
+
+type messageSource struct {
+	src MessageSource
+}
+
+// SetMessageSource set the message source of the registry, nil to remove it.
+func (r *Registry) SetMessageSource(src MessageSource) {
+	r.source.Store(messageSource{src: src})
+}
+
+func (r *Registry) messageSource() MessageSource {
+	ms, _ := r.source.Load().(messageSource)
+	return ms.src
+}
+
+var _ MessageSource = &MessageTable{}
+
+// MessageTable is a MessageSource whose table is swapped atomically,
+// readers never wait for the writer.
+type MessageTable struct {
+	table    atomic.Value // map[int]string, never modified after stored.
+	mx       sync.Mutex
+	watchers map[chan map[int]string]struct{}
+}
+
+// NewMessageTable create a MessageTable with a copy of messages.
+func NewMessageTable(messages map[int]string) *MessageTable {
+	t := &MessageTable{watchers: map[chan map[int]string]struct{}{}}
+	t.table.Store(copyMessages(messages))
+	return t
+}
+
+func copyMessages(messages map[int]string) map[int]string {
+	m := make(map[int]string, len(messages))
+	for k, v := range messages {
+		m[k] = v
+	}
+	return m
+}
+
+func (t *MessageTable) load() map[int]string {
+	m, _ := t.table.Load().(map[int]string)
+	return m
+}
+
+// Get return the message of code.
+func (t *MessageTable) Get(code int) (string, bool) {
+	msg, ok := t.load()[code]
+	return msg, ok
+}
+
+// Swap replace the whole table with a copy of messages and notify the watchers.
+func (t *MessageTable) Swap(messages map[int]string) {
+	m := copyMessages(messages)
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.table.Store(m)
+	for ch := range t.watchers {
+		// only the latest table matters, drop the one not received yet.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- m
+	}
+}
+
+// Watch call fn with the current table and then on every Swap until ctx is done.
+// NOTE: fn must not modify the table.
+func (t *MessageTable) Watch(ctx context.Context, fn func(messages map[int]string)) error {
+	ch := make(chan map[int]string, 1)
+	t.mx.Lock()
+	t.watchers[ch] = struct{}{}
+	t.mx.Unlock()
+	defer func() {
+		t.mx.Lock()
+		delete(t.watchers, ch)
+		t.mx.Unlock()
+	}()
+
+	fn(t.load())
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m := <-ch:
+			fn(m)
+		}
+	}
+}
+
+// FileMessageSource is a MessageSource loaded from a JSON or YAML file which maps code to message,
+// call Poll to reload it when the file changes.
+type FileMessageSource struct {
+	*MessageTable
+	path     string
+	modTime  time.Time
+	mxReload sync.Mutex
+}
+
+// NewFileMessageSource load messages from file, the format is decided by the extension.
+func NewFileMessageSource(path string) (*FileMessageSource, error) {
+	f := &FileMessageSource{MessageTable: NewMessageTable(nil), path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload read the file and swap the table, the table is kept if the file is broken.
+func (f *FileMessageSource) Reload() error {
+	f.mxReload.Lock()
+	defer f.mxReload.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	format, err := catalogFormatOf(f.path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	messages := map[int]string{}
+	switch format {
+	case FormatJSON:
+		err = json.NewDecoder(bytes.NewReader(data)).Decode(&messages)
+	case FormatYAML:
+		err = yaml.UnmarshalStrict(data, &messages)
+	}
+	if err != nil {
+		return fmt.Errorf("ecode: decode message file %s: %v", f.path, err)
+	}
+	f.modTime = info.ModTime()
+	f.Swap(messages)
+	return nil
+}
+
+func (f *FileMessageSource) modified(modTime time.Time) bool {
+	f.mxReload.Lock()
+	defer f.mxReload.Unlock()
+	return !modTime.Equal(f.modTime)
+}
+
+// Poll check the file every interval and reload it when modified, until ctx is done.
+// Reload errors are passed to onError if it is not nil.
+func (f *FileMessageSource) Poll(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		info, err := os.Stat(f.path)
+		if err == nil && !f.modified(info.ModTime()) {
+			continue
+		}
+		if err == nil {
+			err = f.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package errcode
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"net/http"
+	"strconv"
+)
+
+// Severity is the log level of a code.
+type Severity string
+
+const (
+	SeverityDebug Severity = "debug"
+	SeverityInfo  Severity = "info"
+	SeverityWarn  Severity = "warn"
+	SeverityError Severity = "error"
+	SeverityFatal Severity = "fatal"
+)
+
+// Fault tell whether the client or the server is to blame.
+type Fault string
+
+const (
+	FaultClient Fault = "client"
+	FaultServer Fault = "server"
+)
+
+// Meta is the structured metadata of a code.
+type Meta struct {
+	Severity  Severity
+	Retryable bool
+	Category  Fault
+	Owner     string // owning team.
+	DocsURL   string // attached as errdetails.Help when a Status is created.
+}
+
+// keys of Meta in catalog metadata.
+const (
+	metaSeverity  = "severity"
+	metaRetryable = "retryable"
+	metaCategory  = "category"
+	metaOwner     = "owner"
+	metaDocsURL   = "docs_url"
+)
+
+// parseMeta read Meta from the well-known keys of catalog metadata.
+func parseMeta(md map[string]string) (meta Meta, err error) {
+	switch s := Severity(md[metaSeverity]); s {
+	case "", SeverityDebug, SeverityInfo, SeverityWarn, SeverityError, SeverityFatal:
+		meta.Severity = s
+	default:
+		return meta, fmt.Errorf("invalid severity %q", s)
+	}
+	if s, ok := md[metaRetryable]; ok {
+		if meta.Retryable, err = strconv.ParseBool(s); err != nil {
+			return meta, fmt.Errorf("invalid retryable %q", s)
+		}
+	}
+	switch c := Fault(md[metaCategory]); c {
+	case "", FaultClient, FaultServer:
+		meta.Category = c
+	default:
+		return meta, fmt.Errorf("invalid category %q", c)
+	}
+	meta.Owner = md[metaOwner]
+	meta.DocsURL = md[metaDocsURL]
+	return meta, nil
+}
+
+// metadata write Meta to the well-known keys of md, zero fields are skipped.
+func (m Meta) metadata(md map[string]string) {
+	if m.Severity != "" {
+		md[metaSeverity] = string(m.Severity)
+	}
+	if m.Retryable {
+		md[metaRetryable] = "true"
+	}
+	if m.Category != "" {
+		md[metaCategory] = string(m.Category)
+	}
+	if m.Owner != "" {
+		md[metaOwner] = m.Owner
+	}
+	if m.DocsURL != "" {
+		md[metaDocsURL] = m.DocsURL
+	}
+}
+
+// RegisterMeta register metadata of code in the default registry.
+func RegisterMeta(code int, meta Meta) {
+	_defaultRegistry.RegisterMeta(code, meta)
+}
+
+// RegisterMeta register metadata of code.
+func (r *Registry) RegisterMeta(code int, meta Meta) {
+	r.mxInfo.Lock()
+	defer r.mxInfo.Unlock()
+	must(r.checkFrozen())
+	r.metas[code] = meta
+}
+
+// Meta return the metadata of code.
+// If the category is not registered, it is guessed from the http code: 4xx is client fault, 5xx is server fault.
+func (r *Registry) Meta(c Code) Meta {
+	var meta Meta
+	if snap := r.snapshot(); snap != nil {
+		meta = snap.metas[c.Code()]
+	} else {
+		r.mxInfo.RLock()
+		meta = r.metas[c.Code()]
+		r.mxInfo.RUnlock()
+	}
+	if meta.Category == "" {
+		switch httpCode := r.HttpCode(c); {
+		case httpCode >= http.StatusInternalServerError:
+			meta.Category = FaultServer
+		case httpCode >= http.StatusBadRequest:
+			meta.Category = FaultClient
+		}
+	}
+	return meta
+}
+
+// Meta return the metadata of code in the default registry.
+func (e Code) Meta() Meta {
+	return _defaultRegistry.Meta(e)
+}
+
+// Meta return the metadata of code, it is resolved against the registry carried by the context.
+func (s *Status) Meta() Meta {
+	return RegistryFromContext(s.Context()).Meta(Code(s.Code()))
+}
+
+// withDocsURL attach the docs url of code as errdetails.Help.
+func (s *Status) withDocsURL() *Status {
+	if url := s.Meta().DocsURL; url != "" {
+		return s.WithHelp(&errdetails.Help{
+			Links: []*errdetails.Help_Link{{Description: s.Message(), Url: url}},
+		})
+	}
+	return s
+}
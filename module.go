@@ -0,0 +1,137 @@
+package errcode
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Module is a named range of codes reserved in a registry,
+// codes created by Module.New must fall in its range.
+type Module struct {
+	name string
+	from int // first code of the range, the local numbers count from it.
+	to   int
+	r    *Registry
+}
+
+// Name return the module name.
+func (m *Module) Name() string { return m.name }
+
+// Range return the bounds of the module, low <= high.
+func (m *Module) Range() (low, high int) {
+	if m.from < m.to {
+		return m.from, m.to
+	}
+	return m.to, m.from
+}
+
+// Contains report whether e is in the module range.
+func (m *Module) Contains(e int) bool {
+	low, high := m.Range()
+	return low <= e && e <= high
+}
+
+// New a errcode.Codes in the module range.
+// NOTE: it will panic if e is out of the range or already exist.
+func (m *Module) New(e int) Code {
+	if !m.Contains(e) {
+		low, high := m.Range()
+		panic(fmt.Sprintf("ecode: %d out of range [%d, %d] of module %s", e, low, high, m.name))
+	}
+	return mustCode(m.r.addIn(e, m))
+}
+
+// Local new a code by the local number of the module, it counts from the first code of the range.
+// e.g. module reserved by ReserveRange("billing", -20000, -20999), Local(5) is -20005.
+func (m *Module) Local(n int) Code {
+	if n < 0 {
+		panic(fmt.Sprintf("ecode: local number %d of module %s must not less than zero", n, m.name))
+	}
+	if m.from > m.to {
+		return m.New(m.from - n)
+	}
+	return m.New(m.from + n)
+}
+
+// ReserveRange reserve the codes between from and to (both included) for module name.
+// NOTE: the name and range must unique in registry and no code in the range is registered, or it will panic.
+func (r *Registry) ReserveRange(name string, from, to int) *Module {
+	m := &Module{name: name, from: from, to: to, r: r}
+	low, high := m.Range()
+	must(r.CodePolicy().checkRange(low, high))
+	// the codes are locked before the modules as in addIn, so no code is added into the range meanwhile.
+	r.mxCodes.RLock()
+	defer r.mxCodes.RUnlock()
+	r.mxModules.Lock()
+	defer r.mxModules.Unlock()
+	must(r.checkFrozen())
+	for _, o := range r.modules {
+		if o.name == name {
+			panic(fmt.Sprintf("ecode: module %s already exist", name))
+		}
+		if ol, oh := o.Range(); low <= oh && ol <= high {
+			panic(fmt.Sprintf("ecode: range [%d, %d] of module %s overlaps [%d, %d] of module %s",
+				low, high, name, ol, oh, o.name))
+		}
+	}
+	if c, ok := r.registeredIn(low, high); ok {
+		panic(fmt.Sprintf("ecode: range [%d, %d] of module %s contains the registered code %d", low, high, name, c))
+	}
+	r.modules = append(r.modules, m)
+	sort.Slice(r.modules, func(i, j int) bool {
+		li, _ := r.modules[i].Range()
+		lj, _ := r.modules[j].Range()
+		return li < lj
+	})
+	return m
+}
+
+// registeredIn return the lowest registered code between low and high, r.mxCodes must be locked.
+func (r *Registry) registeredIn(low, high int) (int, bool) {
+	found, ok := 0, false
+	for c := range r.codes {
+		if low <= c && c <= high && (!ok || c < found) {
+			found, ok = c, true
+		}
+	}
+	return found, ok
+}
+
+// ReservePrefix reserve width codes for a service prefix, codes are -(prefix*width + local).
+// e.g. ReservePrefix("billing", 20, 1000) reserve -20000 ~ -20999.
+func (r *Registry) ReservePrefix(name string, prefix, width int) *Module {
+	if prefix <= 0 || width <= 0 {
+		panic(fmt.Sprintf("ecode: invalid prefix %d or width %d of module %s", prefix, width, name))
+	}
+	base := -prefix * width
+	return r.ReserveRange(name, base, base-width+1)
+}
+
+// module return the module whose range contains e, nil if e is not reserved.
+func (r *Registry) module(e int) *Module {
+	r.mxModules.RLock()
+	defer r.mxModules.RUnlock()
+	for _, m := range r.modules {
+		if m.Contains(e) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Modules return the reserved modules sorted by range.
+func (r *Registry) Modules() []*Module {
+	r.mxModules.RLock()
+	defer r.mxModules.RUnlock()
+	return append([]*Module(nil), r.modules...)
+}
+
+// ReserveRange reserve a range in the default registry.
+func ReserveRange(name string, from, to int) *Module {
+	return _defaultRegistry.ReserveRange(name, from, to)
+}
+
+// ReservePrefix reserve a service prefix in the default registry.
+func ReservePrefix(name string, prefix, width int) *Module {
+	return _defaultRegistry.ReservePrefix(name, prefix, width)
+}
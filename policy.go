@@ -0,0 +1,90 @@
+package errcode
+
+import (
+	"fmt"
+	"math"
+)
+
+// CodeRange is the codes between Low and High, both included.
+type CodeRange struct {
+	Low  int `json:"low" yaml:"low"`
+	High int `json:"high" yaml:"high"`
+}
+
+func (r CodeRange) contains(low, high int) bool {
+	return r.Low <= low && high <= r.High
+}
+
+func (r CodeRange) overlaps(low, high int) bool {
+	return r.Low <= high && low <= r.High
+}
+
+// CodePolicy decide which codes are reserved for the canonical gRPC codes and which are allowed for business codes.
+// A business code must be in one of the Business ranges and not in any of the Canonical ranges.
+type CodePolicy struct {
+	Canonical []CodeRange
+	Business  []CodeRange
+}
+
+// DefaultCodePolicy reserve 0 ~ 16 for the canonical codes, and allow the codes less than zero for business.
+// e.g. legacy systems using positive codes above 1000 can use:
+//
+//	CodePolicy{
+//		Canonical: []CodeRange{{0, 16}},
+//		Business:  []CodeRange{{math.MinInt32, -1}, {1001, math.MaxInt32}},
+//	}
+func DefaultCodePolicy() CodePolicy {
+	return CodePolicy{
+		Canonical: []CodeRange{{Low: 0, High: 16}},
+		Business:  []CodeRange{{Low: math.MinInt32, High: -1}},
+	}
+}
+
+// checkRange check whether all codes between low and high are allowed for business,
+// they must be in a single business range.
+func (p CodePolicy) checkRange(low, high int) error {
+	for _, c := range p.Canonical {
+		if c.overlaps(low, high) {
+			if low == high {
+				return fmt.Errorf("ecode: %d is reserved for canonical codes [%d, %d]", low, c.Low, c.High)
+			}
+			return fmt.Errorf("ecode: [%d, %d] overlaps canonical codes [%d, %d]", low, high, c.Low, c.High)
+		}
+	}
+	for _, b := range p.Business {
+		if b.contains(low, high) {
+			return nil
+		}
+	}
+	if low == high {
+		return fmt.Errorf("ecode: %d is not allowed for business codes", low)
+	}
+	return fmt.Errorf("ecode: [%d, %d] is not allowed for business codes", low, high)
+}
+
+// check check whether e is allowed for business.
+func (p CodePolicy) check(e int) error {
+	return p.checkRange(e, e)
+}
+
+// SetCodePolicy set the code policy of the default registry.
+func SetCodePolicy(p CodePolicy) {
+	_defaultRegistry.SetCodePolicy(p)
+}
+
+// SetCodePolicy set the code policy which New, RegisterCode, ReserveRange and LoadCatalog validate against.
+// It should be called before any business code is registered.
+func (r *Registry) SetCodePolicy(p CodePolicy) {
+	must(r.checkFrozen())
+	p.Canonical = append([]CodeRange(nil), p.Canonical...)
+	p.Business = append([]CodeRange(nil), p.Business...)
+	r.codePolicy.Store(p)
+}
+
+// CodePolicy return the code policy, default is DefaultCodePolicy.
+func (r *Registry) CodePolicy() CodePolicy {
+	if p, ok := r.codePolicy.Load().(CodePolicy); ok {
+		return p
+	}
+	return DefaultCodePolicy()
+}
@@ -0,0 +1,285 @@
+package errcode
+
+import (
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds a set of codes together with their messages and http codes.
+// Services sharing one binary can keep their codes apart by using their own Registry,
+// the package level functions work on the default registry.
+type Registry struct {
+	messages        map[int]string
+	localized       map[string]map[int]string // locale -> messages
+	mxMessages      sync.RWMutex
+	httpCodes       map[int]int
+	mxHttpCodes     sync.RWMutex
+	codes           map[int]struct{} // register codes.
+	sources         map[int]Source
+	mxCodes         sync.RWMutex
+	modules         []*Module // reserved ranges, sorted.
+	mxModules       sync.RWMutex
+	names           map[int]string
+	metadata        map[int]map[string]string
+	metas           map[int]Meta
+	aliases         map[int]int // old -> new
+	deprecations    map[int]*deprecation
+	mxInfo          sync.RWMutex
+	source          atomic.Value // messageSource
+	frozen          atomic.Value // *frozenTables
+	policy          int32        // ConflictPolicy
+	codePolicy      atomic.Value // CodePolicy
+	deprecationHook atomic.Value // DeprecationHook
+	detailResolver  atomic.Value // *detailResolver
+}
+
+// NewRegistry create an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		messages:     map[int]string{},
+		localized:    map[string]map[int]string{},
+		httpCodes:    map[int]int{},
+		codes:        map[int]struct{}{},
+		sources:      map[int]Source{},
+		names:        map[int]string{},
+		metadata:     map[int]map[string]string{},
+		metas:        map[int]Meta{},
+		aliases:      map[int]int{},
+		deprecations: map[int]*deprecation{},
+	}
+}
+
+var _defaultRegistry = NewRegistry()
+
+// DefaultRegistry return the registry used by the package level functions.
+func DefaultRegistry() *Registry {
+	return _defaultRegistry
+}
+
+func (r *Registry) RegisterMessages(cm map[int]string) {
+	must(r.TryRegisterMessages(cm))
+}
+
+func (r *Registry) RegisterMessage(code int, message string) {
+	must(r.TryRegisterMessage(code, message))
+}
+
+func (r *Registry) RegisterHttpCode(code int, httpCode int) {
+	must(r.TryRegisterHttpCode(code, httpCode))
+}
+
+// TryRegisterMessages register messages, nothing is registered if any of them is rejected by the conflict policy.
+func (r *Registry) TryRegisterMessages(cm map[int]string) error {
+	r.mxMessages.Lock()
+	defer r.mxMessages.Unlock()
+	if err := r.checkFrozen(); err != nil {
+		return err
+	}
+	writes := make(map[int]string, len(cm))
+	for k, v := range cm {
+		if old, ok := r.messages[k]; ok && old != v {
+			write, err := r.resolve(&ConflictError{Kind: ConflictMessage, Code: k, Existing: old, Incoming: v})
+			if err != nil {
+				return err
+			}
+			if !write {
+				continue
+			}
+		}
+		writes[k] = v
+	}
+	for k, v := range writes {
+		r.messages[k] = v
+	}
+	return nil
+}
+
+func (r *Registry) TryRegisterMessage(code int, message string) error {
+	return r.TryRegisterMessages(map[int]string{code: message})
+}
+
+func (r *Registry) TryRegisterHttpCode(code int, httpCode int) error {
+	r.mxHttpCodes.Lock()
+	defer r.mxHttpCodes.Unlock()
+	if err := r.checkFrozen(); err != nil {
+		return err
+	}
+	if old, ok := r.httpCodes[code]; ok && old != httpCode {
+		write, err := r.resolve(&ConflictError{
+			Kind:     ConflictHttpCode,
+			Code:     code,
+			Existing: strconv.Itoa(old),
+			Incoming: strconv.Itoa(httpCode),
+		})
+		if !write {
+			return err
+		}
+	}
+	r.httpCodes[code] = httpCode
+	return nil
+}
+
+// New a errcode.Codes by int value in this registry.
+// NOTE: errcode must unique in registry, the New will check repeat and then panic.
+// Codes in a reserved range must be created by the Module.
+func (r *Registry) New(e int) Code {
+	return mustCode(r.TryNew(e))
+}
+
+// TryNew is New which return error instead of panic, a *ConflictError if the code already exist or is reserved.
+// The code must be allowed for business by the code policy.
+func (r *Registry) TryNew(e int) (Code, error) {
+	if err := r.CodePolicy().check(e); err != nil {
+		return Int(e), err
+	}
+	return r.add(e)
+}
+
+func (r *Registry) exist(e int) bool {
+	r.mxCodes.RLock()
+	defer r.mxCodes.RUnlock()
+	_, ok := r.codes[e]
+	return ok
+}
+
+// add the code, a code in a reserved range can only be added by its module.
+func (r *Registry) add(e int) (Code, error) {
+	return r.addIn(e, nil)
+}
+
+func (r *Registry) addIn(e int, owner *Module) (Code, error) {
+	src := callerSource()
+	r.mxCodes.Lock()
+	defer r.mxCodes.Unlock()
+	if err := r.checkFrozen(); err != nil {
+		return Int(e), err
+	}
+	if m := r.module(e); m != nil && m != owner {
+		return Int(e), &ConflictError{Kind: ConflictRange, Code: e, Existing: m.name}
+	}
+	if _, ok := r.codes[e]; ok {
+		return Int(e), &ConflictError{
+			Kind:           ConflictCode,
+			Code:           e,
+			ExistingSource: r.sources[e],
+			IncomingSource: src,
+		}
+	}
+	r.codes[e] = struct{}{}
+	r.sources[e] = src
+	return Int(e), nil
+}
+
+func (r *Registry) RegisterCode(c code.Code, httpCode int, message string) Code {
+	return mustCode(r.TryRegisterCode(c, httpCode, message))
+}
+
+// TryRegisterCode is RegisterCode which return error instead of panic.
+func (r *Registry) TryRegisterCode(c code.Code, httpCode int, message string) (Code, error) {
+	if err := r.CodePolicy().check(int(c)); err != nil {
+		return Int(int(c)), err
+	}
+	return r.addInt(int(c), httpCode, message)
+}
+
+// addInt add the code then register its message and http code, http code 0 is left to the default.
+// Nothing is added if the message or http code is rejected by the conflict policy.
+func (r *Registry) addInt(c int, httpCode int, message string) (Code, error) {
+	if err := r.checkConflicts(c, httpCode, message); err != nil {
+		return Int(c), err
+	}
+	if _, err := r.add(c); err != nil {
+		return Int(c), err
+	}
+	if err := r.TryRegisterMessage(c, message); err != nil {
+		return Int(c), err
+	}
+	if httpCode == 0 {
+		return Int(c), nil
+	}
+	return Int(c), r.TryRegisterHttpCode(c, httpCode)
+}
+
+// checkConflicts return the conflict of the message or http code of c if the policy is PolicyError.
+func (r *Registry) checkConflicts(c int, httpCode int, message string) error {
+	if r.ConflictPolicy() != PolicyError {
+		return nil
+	}
+	r.mxMessages.RLock()
+	old, ok := r.messages[c]
+	r.mxMessages.RUnlock()
+	if ok && old != message {
+		return &ConflictError{Kind: ConflictMessage, Code: c, Existing: old, Incoming: message}
+	}
+	if httpCode == 0 {
+		return nil
+	}
+	r.mxHttpCodes.RLock()
+	oldHttpCode, ok := r.httpCodes[c]
+	r.mxHttpCodes.RUnlock()
+	if ok && oldHttpCode != httpCode {
+		return &ConflictError{
+			Kind:     ConflictHttpCode,
+			Code:     c,
+			Existing: strconv.Itoa(oldHttpCode),
+			Incoming: strconv.Itoa(httpCode),
+		}
+	}
+	return nil
+}
+
+func (r *Registry) registerEntryInfo(c int, name string, metadata map[string]string) {
+	r.mxInfo.Lock()
+	defer r.mxInfo.Unlock()
+	must(r.checkFrozen())
+	if name != "" {
+		r.names[c] = name
+	}
+	if len(metadata) > 0 {
+		r.metadata[c] = metadata
+	}
+}
+
+// Message return the message of code, or the code in string form if there is none.
+// The message source of the registry is checked first.
+func (r *Registry) Message(c Code) string {
+	if src := r.messageSource(); src != nil {
+		if msg, ok := src.Get(c.Code()); ok {
+			return msg
+		}
+	}
+	if msg, ok := r.message(c.Code()); ok {
+		return msg
+	}
+	return strconv.FormatInt(int64(c), 10)
+}
+
+// HttpCode return the http code of code, default is http.StatusOK.
+func (r *Registry) HttpCode(c Code) int {
+	if httpCode, ok := r.httpCode(c.Code()); ok {
+		return httpCode
+	}
+	return http.StatusOK
+}
+
+type registryKey struct{}
+
+// NewRegistryContext return a copy of ctx which carries r.
+// A Status with this context resolves its message and http code against r.
+func NewRegistryContext(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, registryKey{}, r)
+}
+
+// RegistryFromContext return the registry carried by ctx, or the default registry.
+func RegistryFromContext(ctx context.Context) *Registry {
+	if ctx != nil {
+		if r, ok := ctx.Value(registryKey{}).(*Registry); ok && r != nil {
+			return r
+		}
+	}
+	return _defaultRegistry
+}
@@ -0,0 +1,24 @@
+// Package registrytest help tests to define throwaway codes.
+package registrytest
+
+import (
+	"github.com/SeaseeYoul/errcode"
+	"testing"
+)
+
+// Isolate snapshot the default registry and restore it when t and its subtests finish,
+// so codes defined by the test can be defined again by other tests.
+// NOTE: tests sharing the default registry must not run in parallel with t.
+func Isolate(t testing.TB) {
+	t.Helper()
+	IsolateRegistry(t, errcode.DefaultRegistry())
+}
+
+// IsolateRegistry is Isolate of r.
+func IsolateRegistry(t testing.TB, r *errcode.Registry) {
+	t.Helper()
+	snap := r.Snapshot()
+	t.Cleanup(func() {
+		r.Restore(snap)
+	})
+}
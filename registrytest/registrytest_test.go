@@ -0,0 +1,21 @@
+package registrytest
+
+import (
+	"github.com/SeaseeYoul/errcode"
+	"testing"
+)
+
+func TestIsolate(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		t.Run("define", func(t *testing.T) {
+			Isolate(t)
+			c := errcode.RegisterCode(-80001, 400, "throwaway")
+			errcode.Freeze()
+			errcode.ExpectEQ(t, "throwaway", c.Message())
+		})
+	}
+	_, ok := errcode.Lookup(-80001)
+	errcode.ExpectFalse(t, ok)
+	errcode.ExpectFalse(t, errcode.DefaultRegistry().Frozen())
+	errcode.ExpectEQ(t, "-80001", errcode.Code(-80001).Message())
+}
@@ -0,0 +1,91 @@
+package errcode
+
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"strings"
+)
+
+// Source is where a code is registered.
+type Source struct {
+	File    string `json:"file" yaml:"file"`
+	Line    int    `json:"line" yaml:"line"`
+	Package string `json:"package" yaml:"package"`
+}
+
+func (s Source) String() string {
+	if s.File == "" {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d (%s)", s.File, s.Line, s.Package)
+}
+
+const pkgPath = "github.com/SeaseeYoul/errcode"
+
+// registerFuncs are the package level functions which register codes for their callers.
+var registerFuncs = map[string]bool{
+	"New":             true,
+	"TryNew":          true,
+	"RegisterCode":    true,
+	"TryRegisterCode": true,
+	"LoadCatalog":     true,
+	"LoadCatalogFile": true,
+	"addCode":         true,
+	"addInt":          true,
+	"mustCode":        true,
+}
+
+// callerSource return the first caller outside the registration functions.
+func callerSource() Source {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isRegisterFunc(frame.Function) {
+			return Source{File: frame.File, Line: frame.Line, Package: funcPackage(frame.Function)}
+		}
+		if !more {
+			return Source{}
+		}
+	}
+}
+
+func isRegisterFunc(function string) bool {
+	if !strings.HasPrefix(function, pkgPath+".") {
+		return false
+	}
+	name := strings.TrimPrefix(function, pkgPath+".")
+	return strings.HasPrefix(name, "(*Registry).") || strings.HasPrefix(name, "(*Module).") || registerFuncs[name]
+}
+
+// funcPackage return the package path of a function name like "a/b.(*T).f".
+// The runtime escape the dots in the last element of the path, e.g. "gopkg.in/yaml%2ev2.Unmarshal",
+// they are unescaped.
+func funcPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	pkg := function
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		pkg = function[:slash+1+dot]
+	}
+	if strings.Contains(pkg, "%") {
+		if unescaped, err := url.PathUnescape(pkg); err == nil {
+			pkg = unescaped
+		}
+	}
+	return pkg
+}
+
+// Source return where code is registered in the default registry.
+func (e Code) Source() (Source, bool) {
+	return _defaultRegistry.Source(e)
+}
+
+// Source return where code is registered.
+func (r *Registry) Source(c Code) (Source, bool) {
+	r.mxCodes.RLock()
+	defer r.mxCodes.RUnlock()
+	s, ok := r.sources[c.Code()]
+	return s, ok
+}
@@ -0,0 +1,226 @@
+package errcode
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/anypb"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultStackDepth is the max number of frames captured if StackPolicy.Depth is not set.
+const DefaultStackDepth = 32
+
+// StackMode decide when the stack is captured for a new Status.
+type StackMode int32
+
+const (
+	// StackAlways capture the stack for every Status, it is the default.
+	StackAlways StackMode = iota
+	// StackNever never capture the stack.
+	StackNever
+	// StackSampled capture the stack for a part of Status, see StackPolicy.Rate.
+	StackSampled
+	// StackServerFault capture the stack only if the code is a server fault, see Meta.Category.
+	StackServerFault
+)
+
+// StackPolicy is the stack capture policy of Error, Errorf, Wrap, FromCode, FromError and WrapCodes.
+// Status.WithStack force capture on a single Status whatever the policy is.
+type StackPolicy struct {
+	Mode StackMode
+	// Rate is the fraction of Status which capture the stack in StackSampled mode, between 0 and 1.
+	Rate float64
+	// Depth is the max number of frames captured, default is DefaultStackDepth.
+	Depth int
+}
+
+var (
+	_stackPolicy     atomic.Value // StackPolicy
+	_codeStackPolicy atomic.Value // map[int]StackPolicy, never modified after stored.
+	_mxStackPolicy   sync.Mutex
+)
+
+// SetStackPolicy set the stack policy of codes which have no policy of their own,
+// it can be switched at runtime.
+// The stack policy is process wide as Status is always created against the default registry,
+// it is covered by Snapshot and Restore of the default registry.
+func SetStackPolicy(p StackPolicy) {
+	_stackPolicy.Store(p)
+}
+
+// SetCodeStackPolicy set the stack policy of c, it takes precedence over the one set by SetStackPolicy.
+func SetCodeStackPolicy(c Code, p StackPolicy) {
+	_mxStackPolicy.Lock()
+	defer _mxStackPolicy.Unlock()
+	old := codeStackPolicies()
+	policies := make(map[int]StackPolicy, len(old)+1)
+	for k, v := range old {
+		policies[k] = v
+	}
+	policies[c.Code()] = p
+	_codeStackPolicy.Store(policies)
+}
+
+// stackPolicyOf return the stack policy of c.
+func stackPolicyOf(c Code) StackPolicy {
+	if p, ok := codeStackPolicies()[c.Code()]; ok {
+		return p
+	}
+	p, _ := _stackPolicy.Load().(StackPolicy)
+	return p
+}
+
+func codeStackPolicies() map[int]StackPolicy {
+	policies, _ := _codeStackPolicy.Load().(map[int]StackPolicy)
+	return policies
+}
+
+// stackDepth return the max number of frames captured for c.
+func stackDepth(c Code) int {
+	if depth := stackPolicyOf(c).Depth; depth > 0 {
+		return depth
+	}
+	return DefaultStackDepth
+}
+
+// shouldCaptureStack report whether the stack should be captured for a new Status of c.
+func shouldCaptureStack(c Code) bool {
+	switch p := stackPolicyOf(c); p.Mode {
+	case StackNever:
+		return false
+	case StackSampled:
+		return rand.Float64() < p.Rate
+	case StackServerFault:
+		return _defaultRegistry.Meta(c).Category == FaultServer
+	}
+	return true
+}
+
+// stackSettings is the process wide stack policy and options saved by Snapshot of the default registry.
+type stackSettings struct {
+	policy       StackPolicy
+	codePolicies map[int]StackPolicy
+	options      StackOptions
+}
+
+func saveStackSettings() *stackSettings {
+	s := &stackSettings{codePolicies: codeStackPolicies(), options: loadStackOptions()}
+	s.policy, _ = _stackPolicy.Load().(StackPolicy)
+	return s
+}
+
+func (s *stackSettings) restore() {
+	_stackPolicy.Store(s.policy)
+	_stackOptions.Store(s.options)
+	_mxStackPolicy.Lock()
+	_codeStackPolicy.Store(s.codePolicies)
+	_mxStackPolicy.Unlock()
+}
+
+// captureStack capture the stack if the stack policy of the code allow it.
+// If it doesn't, the text of the cause is still recorded in a errdetails.DebugInfo without stack entries.
+func (s *Status) captureStack(detail string, calldepth int) *Status {
+	if shouldCaptureStack(Code(s.Code())) {
+		return s.withStackEntries(detail, calldepth+1)
+	}
+	if s.cause != nil {
+		if anyMsg, err := anypb.New(&errdetails.DebugInfo{Detail: detail}); err == nil {
+			s.s.Details = append(s.s.Details, anyMsg)
+		}
+	}
+	return s
+}
+
+// WithStack capture the stack even if the stack policy doesn't, nothing is done if the stack is already captured.
+func (s *Status) WithStack() *Status {
+	if s.stacked {
+		return s
+	}
+	detail := s.Error()
+	if s.cause != nil {
+		detail = s.cause.Error()
+	}
+	return s.clone().withStackEntries(detail, 2)
+}
+
+// stack is a captured stack which is not yet symbolized.
+type stack struct {
+	at     int // index in the details.
+	detail string
+	pcs    []uintptr
+}
+
+// render symbolize the stack by opts,
+// as a errdetails.DebugInfo whose entries are "file:line function" and as a Stack with structured frames.
+func (st *stack) render(opts StackOptions) (*errdetails.DebugInfo, *Stack) {
+	entries := make([]string, 0, len(st.pcs))
+	stackFrames := make([]*StackFrame, 0, len(st.pcs))
+	if len(st.pcs) > 0 {
+		frames := runtime.CallersFrames(st.pcs)
+		module, first := "", true
+		for {
+			frame, more := frames.Next()
+			if opts.ModuleOnly {
+				if first {
+					module = moduleOf(frame.Function)
+				} else if moduleOf(frame.Function) != module {
+					break
+				}
+			}
+			first = false
+			if opts.keep(frame) {
+				file := opts.file(frame)
+				entries = append(entries, fmt.Sprintf("%s:%d %s", file, frame.Line, frame.Function))
+				stackFrames = append(stackFrames, &StackFrame{
+					Function: frame.Function,
+					File:     file,
+					Line:     int32(frame.Line),
+					Package:  funcPackage(frame.Function),
+				})
+			}
+			if !more {
+				break
+			}
+		}
+	}
+	debugInfo := &errdetails.DebugInfo{
+		StackEntries: entries,
+		Detail:       st.detail,
+	}
+	return debugInfo, &Stack{Detail: st.detail, Frames: stackFrames}
+}
+
+// symbolize put the captured stacks into the details as errdetails.DebugInfo followed by Stack.
+func (s *Status) symbolize() {
+	s.mx.Lock()
+	s.symbolizeLocked()
+	s.mx.Unlock()
+}
+
+func (s *Status) symbolizeLocked() {
+	if len(s.stacks) == 0 {
+		return
+	}
+	opts := loadStackOptions()
+	for i, st := range s.stacks {
+		debugInfo, stack := st.render(opts)
+		debugInfoAny, err := anypb.New(debugInfo)
+		if err != nil {
+			continue
+		}
+		stackAny, err := anypb.New(stack)
+		if err != nil {
+			continue
+		}
+		// every stack before takes one more place for its Stack.
+		at := st.at + i
+		details := append(s.s.Details, nil, nil)
+		copy(details[at+2:], details[at:])
+		details[at], details[at+1] = debugInfoAny, stackAny
+		s.s.Details = details
+	}
+	s.stacks = nil
+}
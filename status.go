@@ -3,12 +3,12 @@ package errcode
 import (
 	"context"
 	"fmt"
-	"github.com/pkg/errors"
 	"google.golang.org/genproto/googleapis/rpc/code"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -22,35 +22,96 @@ func code2Status(code2 Code) *Status {
 	}
 }
 
-func newError(code Code, message string) *Status {
+// newError new status with code and message, the text of cause is recorded in the DebugInfo instead of the message.
+func newError(code Code, message string, cause error) *Status {
+	_defaultRegistry.use(code)
 	st := code2Status(code)
 	st.s.Message = message
-	return st.withStackEntries(message, 3)
+	st.cause = cause
+	detail := message
+	if cause != nil {
+		detail = cause.Error()
+	}
+	return st.captureStack(detail, 3).withDocsURL()
 }
 
 // Error new status with code and message
 func Error(code Code, message string) *Status {
-	return newError(code, message)
+	return newError(code, message, nil)
 }
 
-// Errorf new status with code and message
+// Errorf new status with code and message,
+// the error of %w is kept as the cause and can be reached by Unwrap.
 func Errorf(code Code, format string, args ...interface{}) *Status {
-	return newError(code, fmt.Sprintf(format, args...))
+	err := fmt.Errorf(format, args...)
+	return newError(code, err.Error(), wrapped(err))
+}
+
+// Wrap new status with code and message which keep err as the cause,
+// errors.Is and errors.As can still reach err by Unwrap.
+func Wrap(err error, code Code, message string) *Status {
+	return newError(code, message, err)
+}
+
+// Wrapf is Wrap with format.
+func Wrapf(err error, code Code, format string, args ...interface{}) *Status {
+	return newError(code, fmt.Sprintf(format, args...), err)
+}
+
+// wrapped return the error wrapped by %w of fmt.Errorf, err itself if it wraps more than one error.
+func wrapped(err error) error {
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		return x.Unwrap()
+	case interface{ Unwrap() []error }:
+		return err
+	}
+	return nil
 }
 
 var _ Codes = &Status{}
 
 // Status statusError is an alias of a status proto
 // implement Codes
+// Status is immutable, every With* return a new Status and leave the old one as it is,
+// so a shared status can be annotated by many goroutines.
 type Status struct {
-	s   *PBStatus
-	ctx context.Context
+	s     *PBStatus
+	ctx   context.Context
+	cause error
+	// stacked is true once the stack is captured.
+	stacked bool
+
+	mx      sync.Mutex
+	stacks  []*stack        // captured but not yet symbolized, see symbolize.
+	details []interface{}   // decoded s.Details, in the same order.
+	decoder *detailResolver // resolver by which details is decoded.
 }
 
+// WithContext return a copy of status with ctx.
 func (s *Status) WithContext(ctx context.Context) Codes {
+	st := s.clone()
+	st.ctx = ctx
+	return st
+}
+
+// clone return a copy of status whose details can be appended without touching s.
+func (s *Status) clone() *Status {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	pb := &PBStatus{}
+	if s.s != nil {
+		pb.Code, pb.Message = s.s.Code, s.s.Message
+		pb.Details = append([]*anypb.Any(nil), s.s.Details...)
+	}
 	return &Status{
-		s:   s.s,
-		ctx: ctx,
+		s:       pb,
+		ctx:     s.ctx,
+		cause:   s.cause,
+		stacked: s.stacked,
+		stacks:  append([]*stack(nil), s.stacks...),
+		details: append([]interface{}(nil), s.details...),
+		decoder: s.decoder,
 	}
 }
 
@@ -101,21 +162,32 @@ func (s *Status) Code() int {
 	return int(s.s.Code)
 }
 
-// Message return error message for developer
+// Message return error message for developer,
+// it is resolved against the registry and locale carried by the context.
 func (s *Status) Message() string {
-	return Code(s.Code()).Message()
+	return s.MessageFor(s.Context())
+}
+
+// MessageFor return error message in the locale carried by ctx.
+func (s *Status) MessageFor(ctx context.Context) string {
+	return Code(s.Code()).MessageFor(ctx)
+}
+
+func (s *Status) HttpCode() int {
+	return RegistryFromContext(s.Context()).HttpCode(Code(s.Code()))
 }
 
-// Details return error details
-func (s *Status) Details() []interface{} {
+func (s *Status) StackEntries() (details []*errdetails.DebugInfo) {
 	if s == nil || s.s == nil {
 		return nil
 	}
-	details := make([]interface{}, 0, len(s.s.Details))
+	s.symbolize()
 	for _, any := range s.s.Details {
 		debugInfo := &errdetails.DebugInfo{}
+		if !any.MessageIs(debugInfo) {
+			continue
+		}
 		if err := any.UnmarshalTo(debugInfo); err != nil {
-			fmt.Printf("unmarshal failed: %v", err)
 			continue
 		}
 		details = append(details, debugInfo)
@@ -123,35 +195,67 @@ func (s *Status) Details() []interface{} {
 	return details
 }
 
-func (s *Status) HttpCode() int {
-	return Code(s.Code()).HttpCode()
+// WithLocalizedMessage attach the message in the locale carried by the context
+// as a errdetails.LocalizedMessage, nothing is attached if there is no locale.
+// Proto attach it anyway, it is only needed to read the detail before the status is sent.
+func (s *Status) WithLocalizedMessage() *Status {
+	d := s.localizedMessage()
+	if d == nil {
+		return s
+	}
+	return s.WithLocalizedMessageDetail(d)
 }
 
-func (s *Status) StackEntries() (details []*errdetails.DebugInfo) {
-	if s == nil || s.s == nil {
+// localizedMessage return the message in the locale carried by the context, nil if there is no locale.
+func (s *Status) localizedMessage() *errdetails.LocalizedMessage {
+	locale, ok := LocaleFromContext(s.Context())
+	if !ok {
 		return nil
 	}
-	for _, any := range s.s.Details {
-		debugInfo := &errdetails.DebugInfo{}
-		if err := any.UnmarshalTo(debugInfo); err != nil {
-			fmt.Printf("unmarshal failed: %v", err)
-			continue
-		}
-		details = append(details, debugInfo)
+	return &errdetails.LocalizedMessage{
+		Locale:  locale,
+		Message: s.Message(),
 	}
-	return details
 }
 
-// WithDetails WithDetails
+// WithDetails return a copy of status with pbs appended to the details, s is returned if any of them fails to marshal.
 func (s *Status) WithDetails(pbs ...proto.Message) (*Status, error) {
+	st := s.clone()
 	for _, pb := range pbs {
 		anyMsg, err := anypb.New(pb)
 		if err != nil {
 			return s, err
 		}
-		s.s.Details = append(s.s.Details, anyMsg)
+		st.s.Details = append(st.s.Details, anyMsg)
+	}
+	return st, nil
+}
+
+// Unwrap return the cause of status, it is nil if the status doesn't wrap an error.
+func (s *Status) Unwrap() error {
+	if s == nil {
+		return nil
+	}
+	return s.cause
+}
+
+// Is report whether target is a Codes with the same code, so errors.Is(err, NotFound) works.
+// A nil target never matches, even though a nil Codes is taken as OK elsewhere.
+func (s *Status) Is(target error) bool {
+	if CheckIsNil(target) {
+		return false
 	}
-	return s, nil
+	c, ok := target.(Codes)
+	return ok && Equal(s, c)
+}
+
+// As set target to the code if target is a *Code.
+func (s *Status) As(target interface{}) bool {
+	if c, ok := target.(*Code); ok {
+		*c = Code(s.Code())
+		return true
+	}
+	return false
 }
 
 // Equal for compatible.
@@ -159,38 +263,61 @@ func (s *Status) Equal(err error) bool {
 	return EqualError(s, err)
 }
 
-// Proto return origin protobuf message
+// Proto return a copy of the protobuf message, the captured stacks are symbolized first.
+// If the context carries a locale, the message in it is attached as a errdetails.LocalizedMessage
+// unless there is one already, see WithLocalizedMessage.
 func (s *Status) Proto() *PBStatus {
-	return s.s
-}
-
-// calldepth 表示跳过的代码深度。数字加一表示高一层
-func (s *Status) withStackEntries(detail string, calldepth int) *Status {
-	var stackEntries []string
-	for ; ; calldepth += 1 {
-		pc, file, line, ok := runtime.Caller(calldepth)
-		if !ok {
-			//fmt.Println(fmt.Printf("calldepth: %v, !no", calldepth))
-			break
-		}
-		_func := runtime.FuncForPC(pc)
-		function := ""
-		if _func != nil {
-			function = _func.Name()
+	if s == nil || s.s == nil {
+		return nil
+	}
+	s.symbolize()
+	pb := &PBStatus{
+		Code:    s.s.Code,
+		Message: s.s.Message,
+		Details: cloneDetails(s.s.Details),
+	}
+	if _, ok := s.LocalizedMessage(); !ok {
+		if d := s.localizedMessage(); d != nil {
+			if anyMsg, err := anypb.New(d); err == nil {
+				pb.Details = append(pb.Details, anyMsg)
+			}
 		}
-		stackEntries = append(stackEntries, fmt.Sprintf("%s:%d %s", file, line, function))
 	}
+	return pb
+}
 
-	debugInfo := &errdetails.DebugInfo{
-		StackEntries: stackEntries,
-		Detail:       detail,
+// cloneDetails deep copy details, PBStatus is copied by hand as its descriptor doesn't match the go type.
+func cloneDetails(details []*anypb.Any) []*anypb.Any {
+	if details == nil {
+		return nil
+	}
+	clones := make([]*anypb.Any, 0, len(details))
+	for _, any := range details {
+		clones = append(clones, proto.Clone(any).(*anypb.Any))
 	}
-	_, _ = s.WithDetails(debugInfo)
+	return clones
+}
+
+// calldepth 表示跳过的代码深度。数字加一表示高一层
+// Only the PCs are recorded here, they are symbolized when the details are read.
+// s is modified, it must be a status not yet returned to the caller.
+func (s *Status) withStackEntries(detail string, calldepth int) *Status {
+	pcs := make([]uintptr, stackDepth(Code(s.Code())))
+	n := runtime.Callers(calldepth+1, pcs)
+	s.mx.Lock()
+	s.stacks = append(s.stacks, &stack{
+		at:     len(s.s.Details) + len(s.stacks),
+		detail: detail,
+		pcs:    pcs[:n],
+	})
+	s.stacked = true
+	s.mx.Unlock()
 	return s
 }
 
+// WithStackEntries return a copy of status with the stack of the caller.
 func (s *Status) WithStackEntries(msg string) *Status {
-	return s.withStackEntries(msg, 2)
+	return s.clone().withStackEntries(msg, 2)
 }
 
 func (s *Status) MergeStackEntries(rhs Codes) *Status {
@@ -199,14 +326,21 @@ func (s *Status) MergeStackEntries(rhs Codes) *Status {
 		s.Detail = fmt.Sprintf(":%v|%v|%s", rhs.Code(), rhs.Message(), s.Detail)
 		buf = append(buf, s)
 	}
-	_, _ = s.WithDetails(buf...)
-	return s
+	if st, ok := rhs.(*Status); ok {
+		for _, stack := range st.Stacks() {
+			stack.Detail = fmt.Sprintf(":%v|%v|%s", rhs.Code(), rhs.Message(), stack.Detail)
+			buf = append(buf, stack)
+		}
+	}
+	st, _ := s.WithDetails(buf...)
+	return st
 }
 
 // FromCode create status from ecode
 func FromCode(code2 Code) *Status {
+	_defaultRegistry.use(code2)
 	st := &Status{s: &PBStatus{Code: code.Code(code2)}}
-	return st.withStackEntries("", 2)
+	return st.captureStack("", 2).withDocsURL()
 }
 
 // WrapCodes create status from Codes
@@ -215,36 +349,45 @@ func WrapCodes(codes Codes) *Status {
 	if st, ok := codes.(*Status); ok {
 		return st
 	} else {
+		_defaultRegistry.use(Code(codes.Code()))
 		st := &Status{s: &PBStatus{Code: code.Code(codes.Code()), Message: codes.Error()}}
-		return st.withStackEntries("", 2)
+		return st.captureStack("", 2).withDocsURL()
 	}
 }
 
-// FromError create status from error
+// FromError create status from error, e is kept as the cause.
 // Pay attention to the difference with Cause()
 func FromError(e error, code2 Code) Codes {
 	if e == nil {
 		return OK
 	}
-	ec, ok := errors.Cause(e).(Codes)
+	ec, ok := findCodes(e)
 	if ok {
 		return ec
 	}
+	_defaultRegistry.use(code2)
 	st := &Status{s: &PBStatus{
 		Code:    code.Code(code2),
 		Message: e.Error(),
-	}}
-	return st.withStackEntries("", 2)
+	}, cause: e}
+	return st.captureStack(e.Error(), 2).withDocsURL()
 }
 
 // FromProto new status from grpc detail
+// aliases are resolved against the default registry, the message is copied so it can be reused by the caller.
 func FromProto(pbMsg proto.Message) Codes {
 	if msg, ok := pbMsg.(*PBStatus); ok {
+		_defaultRegistry.use(Code(msg.Code))
+		c := _defaultRegistry.Resolve(Code(msg.Code))
 		if msg.Message == "" {
-			// NOTE: if message is empty convert to pure Code, will get message from config center.
-			return Code(msg.Code)
+			// NOTE: if message is empty convert to pure Code, will get message from the MessageSource (config center).
+			return c
 		}
-		return &Status{s: msg}
+		return &Status{s: &PBStatus{
+			Code:    code.Code(c),
+			Message: msg.Message,
+			Details: cloneDetails(msg.Details),
+		}}
 	}
-	return newError(Internal, fmt.Sprintf("invalid proto message get %v", pbMsg))
+	return newError(Internal, fmt.Sprintf("invalid proto message get %v", pbMsg), nil)
 }